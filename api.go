@@ -0,0 +1,114 @@
+package main
+
+import (
+	"github.com/lvl5hm/go-bundler/bundleCache"
+	"github.com/lvl5hm/go-bundler/cssLoader"
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+// Options configures a programmatic bundling run, mirroring the
+// json config file
+type Options struct {
+	Mode  string
+	Entry string
+	// BundleDir is only used as the destination for asset files,
+	// leave it empty to keep the build fully in memory
+	BundleDir string
+	// Format is "script", "iife", "umd" or "esm", Library names
+	// the global a umd bundle registers
+	Format  string
+	Library string
+	// Banner and Footer are written around the bundle, outside the
+	// format wrapper
+	Banner     string
+	Footer     string
+	SourceMaps bool
+	Minify     bool
+	Mangle     bool
+	TreeShake  bool
+	Define     map[string]string
+	// Externals maps module names to the globals that stand in for
+	// them, the modules are not bundled
+	Externals map[string]string
+	// DropDebugger removes debugger statements, DropConsole lists
+	// console methods whose standalone calls are removed
+	DropDebugger bool
+	DropConsole  []string
+	// PreserveComments keeps all block comments, /*! banners are
+	// always kept
+	PreserveComments bool
+	// Runtime replaces the module-wrapper glue around every module,
+	// nil keeps the default loader
+	Runtime *RuntimeTemplate
+	Resolve resolveConfig
+	JSX     jsxConfig
+	CSS     cssConfig
+}
+
+// Bundle runs the bundler without writing the output to disk and
+// returns the bundle and its source map. The source map is nil
+// unless SourceMaps is enabled
+func Bundle(opts Options) ([]byte, []byte, error) {
+	config := &configJSON{
+		Mode:             opts.Mode,
+		Entry:            opts.Entry,
+		BundleDir:        opts.BundleDir,
+		Format:           opts.Format,
+		Library:          opts.Library,
+		Banner:           opts.Banner,
+		Footer:           opts.Footer,
+		SourceMaps:       opts.SourceMaps,
+		Minify:           opts.Minify,
+		Mangle:           opts.Mangle,
+		TreeShake:        opts.TreeShake,
+		Define:           opts.Define,
+		Externals:        opts.Externals,
+		DropDebugger:     opts.DropDebugger,
+		DropConsole:      opts.DropConsole,
+		PreserveComments: opts.PreserveComments,
+		Resolve:          opts.Resolve,
+		JSX:              opts.JSX,
+		CSS:              opts.CSS,
+	}
+	applyLoaderOptions(config)
+	Runtime = opts.Runtime
+
+	b := newBundle(config, &bundleCache.Cache{})
+	if err := b.build(); err != nil {
+		return nil, nil, err
+	}
+
+	code, mapBytes := b.generate()
+	return code, mapBytes, nil
+}
+
+// applyLoaderOptions pushes the per-build settings into the loader
+// packages
+func applyLoaderOptions(config *configJSON) {
+	if config.Mode == "production" {
+		config.Minify = true
+		if config.Define == nil {
+			config.Define = map[string]string{}
+		}
+		if _, defined := config.Define["NODE_ENV"]; !defined {
+			config.Define["NODE_ENV"] = "production"
+		}
+	}
+	jsLoader.Resolve.PreserveSymlinks = config.Resolve.PreserveSymlinks
+	jsLoader.Resolve.Extensions = config.Resolve.Extensions
+	jsLoader.Resolve.Alias = config.Resolve.Alias
+	jsLoader.Minify = config.Minify
+	jsLoader.Mangle = config.Mangle
+	jsLoader.Define = config.Define
+	jsLoader.Externals = config.Externals
+	jsLoader.JSXPragma = config.JSX.Pragma
+	jsLoader.JSXFragment = config.JSX.Fragment
+	jsLoader.DropDebugger = config.DropDebugger
+	jsLoader.DropConsole = config.DropConsole
+	jsLoader.PreserveComments = config.PreserveComments
+	cssLoader.Options = cssLoader.LoaderOptions{
+		BundleImports: config.CSS.BundleImports,
+		PublicPath:    config.CSS.PublicPath,
+		Extract:       config.CSS.Extract,
+	}
+}
@@ -0,0 +1,910 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvl5hm/go-bundler/bundleCache"
+	"github.com/lvl5hm/go-bundler/cssLoader"
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+func TestBundleInMemory(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	dep := filepath.Join(tmp, "dep.js")
+	if err := os.WriteFile(dep, []byte("export var answer = 42;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(entry,
+		[]byte("import { answer } from './dep.js'; console.log(answer);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	code, mapBytes, err := Bundle(Options{Entry: entry, SourceMaps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(code)
+	if !strings.Contains(bundle, "var answer = 42") {
+		t.Errorf("expected dep code in the bundle, got:\n%s", bundle)
+	}
+	if !strings.Contains(bundle, "console.log(answer)") {
+		t.Errorf("expected entry code in the bundle, got:\n%s", bundle)
+	}
+	if !strings.Contains(string(mapBytes), "\"version\":3") {
+		t.Errorf("expected a version 3 source map, got %s", mapBytes)
+	}
+}
+
+func TestBundleReportsErrors(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "broken.js")
+	if err := os.WriteFile(entry, []byte("var = ;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := Bundle(Options{Entry: entry})
+	if err == nil {
+		t.Error("expected an error for a file that does not parse")
+	}
+}
+
+func TestCreateBundleToWriter(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry, []byte("console.log('hi');"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	config := &configJSON{Entry: entry}
+	_, err := createBundleTo(&buf, config, &bundleCache.Cache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "console.log('hi')") {
+		t.Errorf("expected entry code in the stream, got:\n%s", buf.String())
+	}
+}
+
+func TestBundleDefine(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	src := "if (process.env.NODE_ENV === 'production') { enable(); } use(process.env.API_URL);"
+	if err := os.WriteFile(entry, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := Bundle(Options{
+		Entry:  entry,
+		Define: map[string]string{"NODE_ENV": "production", "API_URL": "https://api.test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(code)
+	if !strings.Contains(bundle, "enable()") || strings.Contains(bundle, "'production'") {
+		t.Errorf("expected the NODE_ENV comparison to be resolved, got:\n%s", bundle)
+	}
+	if !strings.Contains(bundle, `use("https://api.test")`) {
+		t.Errorf("expected API_URL to be substituted inline, got:\n%s", bundle)
+	}
+	if !strings.Contains(bundle, `var process = {env: {API_URL: "https://api.test", NODE_ENV: "production"}};`) {
+		t.Errorf("expected the process.env object in the head, got:\n%s", bundle)
+	}
+}
+
+func TestProductionMode(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	src := "if (process.env.NODE_ENV !== 'production') { devWarning(); } run();"
+	if err := os.WriteFile(entry, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := Bundle(Options{Mode: "production", Entry: entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(code)
+	if strings.Contains(bundle, "devWarning") {
+		t.Errorf("expected the dev-only branch to be folded away, got:\n%s", bundle)
+	}
+	if !strings.Contains(bundle, "run()") {
+		t.Errorf("expected live code to stay, got:\n%s", bundle)
+	}
+	if !strings.Contains(bundle, `NODE_ENV: "production"`) {
+		t.Errorf("expected NODE_ENV production in the process object, got:\n%s", bundle)
+	}
+}
+
+func TestDynamicImportChunk(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "route.js"),
+		[]byte("export default function route() { return 'route'; }"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("import('./route.js').then(function(m) { m.default(); });"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{Entry: entry, BundleDir: bundleDir}
+	_, err := createBundle(config, &bundleCache.Cache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := os.ReadFile(filepath.Join(bundleDir, "bundle.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routePath := filepath.Join(tmp, "route.js")
+	chunkName := jsLoader.ChunkFileName(routePath)
+	if !strings.Contains(string(code), "__goBundlerImport('"+chunkName+"'") {
+		t.Errorf("expected the bundle to load the chunk at runtime, got:\n%s", code)
+	}
+	if strings.Contains(string(code), "return 'route'") {
+		t.Errorf("dynamically imported code must not be inlined, got:\n%s", code)
+	}
+
+	chunk, err := os.ReadFile(filepath.Join(bundleDir, chunkName))
+	if err != nil {
+		t.Fatalf("expected a chunk file: %v", err)
+	}
+	if !strings.Contains(string(chunk), "return 'route'") {
+		t.Errorf("expected route code in the chunk, got:\n%s", chunk)
+	}
+	if !strings.Contains(string(chunk), "_namespace = {default:") {
+		t.Errorf("expected the chunk to build the namespace object, got:\n%s", chunk)
+	}
+}
+
+func TestParseConfigRejectsUnknownKeys(t *testing.T) {
+	_, err := parseConfig([]byte(`{"bundleDirr": "out"}`), "config.json")
+	if err == nil || !strings.Contains(err.Error(), "bundleDirr") {
+		t.Errorf("expected an unknown-field error, got %v", err)
+	}
+
+	config, err := parseConfig([]byte(`{"bundleDir": "out"}`), "config.json")
+	if err != nil || config.BundleDir != "out" {
+		t.Errorf("expected a valid config, got %v, %v", config, err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry, []byte("use(1);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	err := validateConfig(&configJSON{Entry: entry})
+	if err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+
+	err = validateConfig(&configJSON{
+		Entry:     filepath.Join(tmp, "missing.js"),
+		DevServer: devServerConfig{Port: 123456},
+	})
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, key := range []string{"entry:", "devServer.port:"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected %s in the message, got %v", key, err)
+		}
+	}
+}
+
+func TestBuildReportsAllFailedFiles(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.js", "var = broken;")
+	write("b.js", "function ( {}")
+	entry := filepath.Join(tmp, "index.js")
+	write("index.js", "import './a.js'; import './b.js';")
+
+	_, _, err := Bundle(Options{Entry: entry})
+	if err == nil {
+		t.Fatal("expected the build to fail")
+	}
+	msg := err.Error()
+	for _, name := range []string{"a.js", "b.js"} {
+		if !strings.Contains(msg, filepath.Join(tmp, name)) {
+			t.Errorf("expected %s in the failure summary, got:\n%s", name, msg)
+		}
+	}
+}
+
+func TestCircularDependencyRuntime(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "a.js"),
+		[]byte("import { name } from './b.js'; export function greet() { return 'hi ' + name; }"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "b.js"),
+		[]byte("import { greet } from './a.js'; export var name = 'bob'; export var greeting = greet();"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("import { greeting } from './b.js'; use(greeting);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := Bundle(Options{Entry: entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(code)
+	aName := jsLoader.CreateVarNameFromPath(filepath.Join(tmp, "a.js"))
+	bName := jsLoader.CreateVarNameFromPath(filepath.Join(tmp, "b.js"))
+	// module functions must guard against running twice so the
+	// re-entrant call of a cycle returns instead of recursing
+	if !strings.Contains(bundle, "if ("+aName+".hasRun) return;") {
+		t.Errorf("expected a run-once guard in the module function, got:\n%s", bundle)
+	}
+	// b initializes a before its own body runs, so greet is
+	// defined by the time b's top level calls it
+	if !strings.Contains(bundle, bName+".hasRun = true;\n"+aName+"();") {
+		t.Errorf("expected b to initialize its dependency first, got:\n%s", bundle)
+	}
+}
+
+func TestCustomRuntimeTemplate(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "dep.js"),
+		[]byte("export var n = 1;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("import { n } from './dep.js'; use(n);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime := &RuntimeTemplate{
+		ModuleOpen: func(moduleName string, imports []string) string {
+			out := "function " + moduleName + "() {\n"
+			out += "if (__seen[" + strconv.Quote(moduleName) + "]) return;\n"
+			out += "__seen[" + strconv.Quote(moduleName) + "] = true;\n"
+			for _, imp := range imports {
+				out += imp + "();\n"
+			}
+			return out
+		},
+		ModuleClose: func(moduleName, epilogue string) string {
+			return "\n" + epilogue + "}\n"
+		},
+		Tail: func(entryName string) string {
+			return "var __seen = {};\n" + entryName + "();\n"
+		},
+	}
+	code, _, err := Bundle(Options{Entry: entry, Runtime: runtime})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(code)
+
+	entryName := jsLoader.CreateVarNameFromPath(entry)
+	if !strings.Contains(bundle, "if (__seen["+strconv.Quote(entryName)+"]) return;") {
+		t.Errorf("expected the custom module wrapper, got:\n%s", bundle)
+	}
+	if strings.Contains(bundle, ".hasRun") {
+		t.Errorf("expected no default runtime glue, got:\n%s", bundle)
+	}
+	if !strings.Contains(bundle, "var __seen = {};\n"+entryName+"();") {
+		t.Errorf("expected the custom tail, got:\n%s", bundle)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("export var answer = 42; export default answer;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entryName := jsLoader.CreateVarNameFromPath(entry)
+
+	iife, _, err := Bundle(Options{Entry: entry, Format: "iife"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(iife), "(function() {\n") ||
+		!strings.HasSuffix(string(iife), "})();\n") {
+		t.Errorf("expected an iife wrapper, got:\n%s", iife)
+	}
+
+	umd, _, err := Bundle(Options{Entry: entry, Format: "umd", Library: "answers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"define(factory)",
+		"module.exports = factory()",
+		"root.answers = factory()",
+		"return {answer: " + entryName + "_answer, default: " + entryName + "_default};",
+	} {
+		if !strings.Contains(string(umd), want) {
+			t.Errorf("expected %q in the umd bundle, got:\n%s", want, umd)
+		}
+	}
+
+	esm, _, err := Bundle(Options{Entry: entry, Format: "esm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"export var answer = " + entryName + "_answer;",
+		"export default " + entryName + "_default;",
+	} {
+		if !strings.Contains(string(esm), want) {
+			t.Errorf("expected %q in the esm bundle, got:\n%s", want, esm)
+		}
+	}
+}
+
+func TestBannerAndFooter(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry, []byte("use(1);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := Bundle(Options{
+		Entry:  entry,
+		Format: "iife",
+		Banner: "/*! mylib v[version] built [date] */",
+		Footer: "// end of bundle",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(code)
+
+	// the version comes from whatever package.json the working
+	// directory has, the test only pins the date
+	if !strings.HasPrefix(bundle, "/*! mylib v") ||
+		!strings.Contains(bundle,
+			" built "+time.Now().Format("2006-01-02")+" */\n(function() {\n") {
+		t.Errorf("expected the banner before the format wrapper, got:\n%s", bundle)
+	}
+	if !strings.HasSuffix(bundle, "})();\n// end of bundle\n") {
+		t.Errorf("expected the footer after the format wrapper, got:\n%s", bundle)
+	}
+}
+
+func TestInlineAssetQuery(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "logo.png"), []byte("fakepng"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("import url from './logo.png?inline'; use(url);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{Entry: entry, BundleDir: bundleDir}
+	b, err := createBundle(config, &bundleCache.Cache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := os.ReadFile(filepath.Join(bundleDir, "bundle.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "data:image/png;base64,") {
+		t.Errorf("expected an inlined data uri, got:\n%s", code)
+	}
+	// nothing was copied, so the asset manifest stays empty
+	if len(b.assetManifest) != 0 {
+		t.Errorf("expected no copied assets, got %v", b.assetManifest)
+	}
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.js", "export var a = 1;")
+	write("b.js", "export var b = 2;")
+	write("c.js", "export * from './a.js'; export * from './b.js';")
+	write("route.js", "export default function() { return 'route'; }")
+	entry := filepath.Join(tmp, "index.js")
+	write("index.js",
+		"import * as c from './c.js'; import('./route.js'); use(c.a, c.b);")
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{Entry: entry, BundleDir: bundleDir}
+	readBuild := func() map[string]string {
+		t.Helper()
+		if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+			t.Fatal(err)
+		}
+		files, err := os.ReadDir(bundleDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[string]string{}
+		for _, f := range files {
+			content, err := os.ReadFile(filepath.Join(bundleDir, f.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out[f.Name()] = string(content)
+		}
+		return out
+	}
+
+	first := readBuild()
+	for run := 0; run < 3; run++ {
+		again := readBuild()
+		for name, content := range first {
+			if again[name] != content {
+				t.Fatalf("output file %s differs between identical builds", name)
+			}
+		}
+		if len(again) != len(first) {
+			t.Fatalf("build produced a different file set: %d vs %d", len(again), len(first))
+		}
+	}
+}
+
+func TestStarReexportConflicts(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.js", "export var shared = 'a'; export var onlyA = 1;")
+	write("b.js", "export var shared = 'b'; export var onlyB = 2;")
+	write("lib.js",
+		"export * from './a.js'; export * from './a.js'; export * from './b.js'; export var shared = 'own';")
+	entry := filepath.Join(tmp, "index.js")
+	write("index.js", "import { shared, onlyA, onlyB } from './lib.js'; use(shared, onlyA, onlyB);")
+
+	code, _, err := Bundle(Options{Entry: entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(code)
+	libName := jsLoader.CreateVarNameFromPath(filepath.Join(tmp, "lib.js"))
+	aName := jsLoader.CreateVarNameFromPath(filepath.Join(tmp, "a.js"))
+	// the module's own export shadows the star-reexported name
+	if strings.Contains(bundle, libName+"_shared = "+aName+"_shared") {
+		t.Errorf("expected the explicit export to win over the star reexport, got:\n%s", bundle)
+	}
+	// reexporting a.js twice must copy its names only once
+	if strings.Count(bundle, libName+"_onlyA = "+aName+"_onlyA") != 1 {
+		t.Errorf("expected a single copy for a duplicated star reexport, got:\n%s", bundle)
+	}
+}
+
+func TestBundleHTMLInjection(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry, []byte("use(1);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(template string) string {
+		t.Helper()
+		templatePath := filepath.Join(tmp, "template.html")
+		if err := os.WriteFile(templatePath, []byte(template), 0666); err != nil {
+			t.Fatal(err)
+		}
+		bundleDir := filepath.Join(tmp, "build")
+		config := &configJSON{Entry: entry, BundleDir: bundleDir, TemplateHTML: templatePath}
+		if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+			t.Fatal(err)
+		}
+		html, err := os.ReadFile(filepath.Join(bundleDir, "index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(html)
+	}
+
+	scriptTag := "<script src=\"bundle.js\"></script>\n"
+
+	// minified template, no newline before </body>
+	html := build("<html><body><div></div></body></html>")
+	if html != "<html><body><div></div>"+scriptTag+"</body></html>" {
+		t.Errorf("expected the script before </body>, got %s", html)
+	}
+
+	// closing tags match case-insensitively with stray whitespace
+	html = build("<HTML><BODY></BODY ></HTML>")
+	if !strings.Contains(html, scriptTag+"</BODY >") {
+		t.Errorf("expected the script before </BODY >, got %s", html)
+	}
+
+	// a template without a body falls back to </html>
+	html = build("<html><div></div></html>")
+	if !strings.Contains(html, scriptTag+"</html>") {
+		t.Errorf("expected the script before </html>, got %s", html)
+	}
+
+	// with neither tag the script goes at the end
+	html = build("<div>bare</div>")
+	if !strings.HasSuffix(html, scriptTag) {
+		t.Errorf("expected the script appended, got %s", html)
+	}
+}
+
+func TestHashedBundleName(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "template.html"),
+		[]byte("<html><body></body></html>"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry, []byte("use(1);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{
+		Entry:        entry,
+		BundleDir:    bundleDir,
+		BundleName:   "bundle.[contenthash].js",
+		TemplateHTML: filepath.Join(tmp, "template.html"),
+		SourceMaps:   true,
+		Manifest:     manifestConfig{Enable: true},
+	}
+	if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(bundleDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundleName := ""
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasPrefix(name, "bundle.") && strings.HasSuffix(name, ".js") {
+			bundleName = name
+		}
+	}
+	if bundleName == "" || strings.Contains(bundleName, "[") {
+		t.Fatalf("expected a hashed bundle name, got files %v", files)
+	}
+
+	// the bundle references its own map under the hashed name
+	code, err := os.ReadFile(filepath.Join(bundleDir, bundleName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "sourceMappingURL="+bundleName+".map") {
+		t.Errorf("expected the map comment to use the hashed name, got:\n%s", code)
+	}
+	if _, err := os.Stat(filepath.Join(bundleDir, bundleName+".map")); err != nil {
+		t.Errorf("expected the source map next to the bundle: %v", err)
+	}
+
+	html, err := os.ReadFile(filepath.Join(bundleDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "<script src=\""+bundleName+"\">") {
+		t.Errorf("expected the hashed name in the template, got %s", html)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(bundleDir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("expected manifest.json: %v", err)
+	}
+	manifest := map[string]string{}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest["bundle.js"] != bundleName {
+		t.Errorf("expected the manifest to map bundle.js to %s, got %v", bundleName, manifest)
+	}
+}
+
+func TestPublicDir(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry, []byte("use(1);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	publicDir := filepath.Join(tmp, "public")
+	if err := os.MkdirAll(filepath.Join(publicDir, "meta"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(publicDir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("favicon.ico", "icon")
+	write(filepath.Join("meta", "robots.txt"), "User-agent: *")
+	// conflicts with the generated bundle and must not replace it
+	write("bundle.js", "not the real bundle")
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{Entry: entry, BundleDir: bundleDir, PublicDir: publicDir}
+	if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+		t.Fatal(err)
+	}
+
+	icon, err := os.ReadFile(filepath.Join(bundleDir, "favicon.ico"))
+	if err != nil || string(icon) != "icon" {
+		t.Errorf("expected favicon.ico copied, got %q, %v", icon, err)
+	}
+	robots, err := os.ReadFile(filepath.Join(bundleDir, "meta", "robots.txt"))
+	if err != nil || string(robots) != "User-agent: *" {
+		t.Errorf("expected the subdirectory preserved, got %q, %v", robots, err)
+	}
+	code, err := os.ReadFile(filepath.Join(bundleDir, "bundle.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(code) == "not the real bundle" {
+		t.Error("expected the generated bundle to survive the conflict")
+	}
+}
+
+func TestManifestConfig(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "logo.png"), []byte("fakepng"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("import url from './logo.png'; use(url);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// disabled by default, even with copied assets
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{Entry: entry, BundleDir: bundleDir}
+	if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(bundleDir, "manifest.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no manifest without the flag, got %v", err)
+	}
+
+	// enabled with a custom path
+	manifestPath := filepath.Join(tmp, "assets.json")
+	config.Manifest = manifestConfig{Enable: true, Path: manifestPath}
+	if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("expected the manifest at the configured path: %v", err)
+	}
+	manifest := map[string]string{}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	logoPath := filepath.Join(tmp, "logo.png")
+	if !strings.HasPrefix(manifest[logoPath], "logo.") {
+		t.Errorf("expected the asset mapping for %s, got %v", logoPath, manifest)
+	}
+}
+
+func TestCSSExtraction(t *testing.T) {
+	defer func() { cssLoader.Options = cssLoader.LoaderOptions{} }()
+	cssLoader.Options = cssLoader.LoaderOptions{Extract: true}
+
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "style.css"),
+		[]byte(".a { color: red; }"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "template.html"),
+		[]byte("<html><head></head><body></body></html>"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("import './style.css'; use(1);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{
+		Entry:        entry,
+		BundleDir:    bundleDir,
+		TemplateHTML: filepath.Join(tmp, "template.html"),
+	}
+	if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+		t.Fatal(err)
+	}
+
+	css, err := os.ReadFile(filepath.Join(bundleDir, "bundle.css"))
+	if err != nil {
+		t.Fatalf("expected bundle.css: %v", err)
+	}
+	if !strings.Contains(string(css), ".a { color: red; }") {
+		t.Errorf("expected the extracted rules, got %s", css)
+	}
+
+	code, err := os.ReadFile(filepath.Join(bundleDir, "bundle.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(code), "document.createElement('style')") {
+		t.Errorf("expected no runtime style injection, got:\n%s", code)
+	}
+
+	html, err := os.ReadFile(filepath.Join(bundleDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := "<link rel=\"stylesheet\" href=\"bundle.css\">\n"
+	if !strings.Contains(string(html), link+"</head>") {
+		t.Errorf("expected the stylesheet link in the head, got %s", html)
+	}
+}
+
+func TestStatsReport(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "dep.js"),
+		[]byte("export var v = 1;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry,
+		[]byte("import { v } from './dep.js'; use(v);"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{Entry: entry, BundleDir: bundleDir, Stats: true}
+	_, err := createBundle(config, &bundleCache.Cache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(bundleDir, "stats.json"))
+	if err != nil {
+		t.Fatalf("expected stats.json: %v", err)
+	}
+	var stats []moduleStats
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected two modules in the report, got %v", stats)
+	}
+	if stats[1].Path != entry || len(stats[1].Imports) != 1 || stats[1].Size == 0 {
+		t.Errorf("unexpected entry stats: %+v", stats[1])
+	}
+}
+
+func TestCompressedOutput(t *testing.T) {
+	tmp := t.TempDir()
+	entry := filepath.Join(tmp, "index.js")
+	if err := os.WriteFile(entry, []byte("use('compress me');"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{
+		Entry:     entry,
+		BundleDir: bundleDir,
+		Compress:  []string{"gzip"},
+	}
+	if _, err := createBundle(config, &bundleCache.Cache{}); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := os.ReadFile(filepath.Join(bundleDir, "bundle.js.gz"))
+	if err != nil {
+		t.Fatalf("expected a gzip variant next to the bundle: %v", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := os.ReadFile(filepath.Join(bundleDir, "bundle.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unpacked, bundle) {
+		t.Error("expected the gzip variant to unpack to the bundle")
+	}
+
+	// brotli has no encoder in the standard library and fails loudly
+	err = validateConfig(&configJSON{Entry: entry, Compress: []string{"brotli"}})
+	if err == nil || !strings.Contains(err.Error(), "compress:") {
+		t.Errorf("expected a compress validation error, got %v", err)
+	}
+}
+
+func TestIncrementalRebuild(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("dep.js", "export var answer = 42;")
+	write("extra.js", "export var extra = 1;")
+	entry := filepath.Join(tmp, "index.js")
+	write("index.js",
+		"import { answer } from './dep.js'; import { extra } from './extra.js'; use(answer, extra);")
+
+	bundleDir := filepath.Join(tmp, "build")
+	config := &configJSON{Entry: entry, BundleDir: bundleDir}
+	b, err := createBundle(config, &bundleCache.Cache{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the dep changes and the entry stops importing extra.js
+	write("dep.js", "export var answer = 43;")
+	write("index.js", "import { answer } from './dep.js'; use(answer);")
+	depPath := filepath.Join(tmp, "dep.js")
+	if err := b.rebuild([]string{depPath, entry}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.writeOutputs(); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := os.ReadFile(filepath.Join(bundleDir, "bundle.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "var answer = 43") {
+		t.Errorf("expected the changed dep in the bundle, got:\n%s", code)
+	}
+	if strings.Contains(string(code), "extra = 1") {
+		t.Errorf("expected the dropped import to leave the bundle, got:\n%s", code)
+	}
+	if _, loaded := b.files[filepath.Join(tmp, "extra.js")]; loaded {
+		t.Error("expected the unreachable file to be dropped from the graph")
+	}
+
+	// a broken change reports the error and the next rebuild recovers
+	write("dep.js", "var = broken;")
+	if err := b.rebuild([]string{depPath}); err == nil {
+		t.Fatal("expected the rebuild to fail on a parse error")
+	}
+	write("dep.js", "export var answer = 44;")
+	if err := b.rebuild([]string{depPath}); err != nil {
+		t.Fatalf("expected the rebuild to recover, got %v", err)
+	}
+}
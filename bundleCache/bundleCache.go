@@ -0,0 +1,144 @@
+package bundleCache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+// CacheEntry stores the load result for a single file along with
+// the stats and content hash it was generated from
+type CacheEntry struct {
+	ModTime int64
+	Size    int64
+	Hash    string
+	Data    jsLoader.FileData
+}
+
+func contentHash(content []byte) string {
+	hash := sha1.Sum(content)
+	return hex.EncodeToString(hash[:])
+}
+
+type Cache struct {
+	dirName string
+	// mu guards entries, the bundler loads files from parallel
+	// workers that all share the cache
+	mu      sync.RWMutex
+	entries map[string]CacheEntry
+}
+
+const cacheFileName = "cache.json"
+
+// cacheVersion is the on-disk format version, bump it whenever
+// CacheEntry or jsLoader.FileData changes shape so a cache written
+// by an older build is discarded instead of decoded incorrectly
+const cacheVersion = 1
+
+// cacheFile is what actually gets written to disk
+type cacheFile struct {
+	Version int
+	Entries map[string]CacheEntry
+}
+
+// Load reads the cache file from a previous run, a missing,
+// broken or outdated cache file just means starting from scratch
+func Load(dirName string) *Cache {
+	c := &Cache{
+		dirName: dirName,
+		entries: map[string]CacheEntry{},
+	}
+
+	file, err := os.ReadFile(filepath.Join(dirName, cacheFileName))
+	if err != nil {
+		return c
+	}
+	var stored cacheFile
+	if json.Unmarshal(file, &stored) != nil || stored.Version != cacheVersion {
+		return c
+	}
+	if stored.Entries != nil {
+		c.entries = stored.Entries
+	}
+	return c
+}
+
+// Get returns the cached load result for a file if its content
+// has not changed since it was cached. When size and modification
+// time still match the content is assumed unchanged without
+// reading the file; otherwise the content hash decides, so a git
+// checkout that only touches timestamps keeps the cache warm. A
+// zero value Cache acts as a disabled cache that never hits
+func (c *Cache) Get(path string) (jsLoader.FileData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[path]
+	if !ok {
+		return jsLoader.FileData{}, false
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return jsLoader.FileData{}, false
+	}
+	if stat.ModTime().Unix() == entry.ModTime && stat.Size() == entry.Size {
+		return entry.Data, true
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || contentHash(content) != entry.Hash {
+		return jsLoader.FileData{}, false
+	}
+	// remember the new stats so the next lookup is cheap again
+	entry.ModTime = stat.ModTime().Unix()
+	entry.Size = stat.Size()
+	c.entries[path] = entry
+	return entry.Data, true
+}
+
+func (c *Cache) Put(path string, data jsLoader.FileData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		return
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	c.entries[path] = CacheEntry{
+		ModTime: stat.ModTime().Unix(),
+		Size:    stat.Size(),
+		Hash:    contentHash(content),
+		Data:    data,
+	}
+}
+
+// Save writes the cache to disk for the next run
+func (c *Cache) Save() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.entries == nil {
+		return nil
+	}
+	err := os.MkdirAll(c.dirName, 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cacheFile{
+		Version: cacheVersion,
+		Entries: c.entries,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.dirName, cacheFileName), data, 0666)
+}
@@ -0,0 +1,113 @@
+package bundleCache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+func TestGetSurvivesTouchedModTime(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "file.js")
+	if err := os.WriteFile(path, []byte("var a = 1;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Load(filepath.Join(tmp, "cache"))
+	c.Put(path, jsLoader.FileData{Code: []byte("cached")})
+
+	// a checkout or copy changes the mod time but not the content
+	newTime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, newTime, newTime); err != nil {
+		t.Fatal(err)
+	}
+
+	data, ok := c.Get(path)
+	if !ok || string(data.Code) != "cached" {
+		t.Errorf("expected a hit for unchanged content, got ok=%v data=%+v", ok, data)
+	}
+}
+
+func TestGetMissesOnChangedContent(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "file.js")
+	if err := os.WriteFile(path, []byte("var a = 1;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Load(filepath.Join(tmp, "cache"))
+	c.Put(path, jsLoader.FileData{Code: []byte("cached")})
+
+	// same size, different content; force the mod time to change so
+	// the hash check runs even on filesystems with coarse timestamps
+	if err := os.WriteFile(path, []byte("var b = 2;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	newTime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, newTime, newTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.Get(path); ok {
+		t.Error("expected a miss for changed content")
+	}
+}
+
+func TestSaveAndLoadRoundTrip(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "file.js")
+	if err := os.WriteFile(path, []byte("var a = 1;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := filepath.Join(tmp, "cache")
+	c := Load(cacheDir)
+	c.Put(path, jsLoader.FileData{Code: []byte("cached")})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := Load(cacheDir)
+	data, ok := loaded.Get(path)
+	if !ok || string(data.Code) != "cached" {
+		t.Errorf("expected the saved entry to load, got ok=%v data=%+v", ok, data)
+	}
+}
+
+func TestLoadDiscardsOldFormatVersion(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "file.js")
+	if err := os.WriteFile(path, []byte("var a = 1;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := filepath.Join(tmp, "cache")
+	c := Load(cacheDir)
+	c.Put(path, jsLoader.FileData{Code: []byte("cached")})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath := filepath.Join(cacheDir, cacheFileName)
+	raw, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := bytes.Replace(raw,
+		[]byte(`"Version":1`), []byte(`"Version":0`), 1)
+	if bytes.Equal(stale, raw) {
+		t.Fatal("expected a version field in the cache file")
+	}
+	if err := os.WriteFile(cachePath, stale, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := Load(cacheDir)
+	if _, ok := loaded.Get(path); ok {
+		t.Error("expected a cache with a wrong version to be discarded")
+	}
+}
@@ -0,0 +1,1157 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lvl5hm/go-bundler/bundleCache"
+	"github.com/lvl5hm/go-bundler/cssLoader"
+	"github.com/lvl5hm/go-bundler/jsLoader"
+	"github.com/lvl5hm/go-bundler/loaderUtils"
+)
+
+type bundle struct {
+	files       map[string]*jsLoader.FileData
+	moduleOrder []string
+	config      *configJSON
+	cache       *bundleCache.Cache
+	// cycles maps an importing file to imports of it that were
+	// still being loaded at the time, i.e. circular dependencies
+	cycles map[string][]string
+	// assetManifest maps original asset paths to their hashed
+	// names in the bundle directory, assetMu guards it against
+	// parallel load workers
+	assetManifest map[string]string
+	assetMu       sync.Mutex
+	// sourceMap holds the serialized source map of the last
+	// generate run, nil unless source maps are enabled
+	sourceMap []byte
+	// css accumulates the stylesheet text extracted by the css
+	// loader, cssSeen keeps modules from contributing twice when
+	// they appear in several chunks
+	css     []byte
+	cssSeen map[string]bool
+	// bundleName is the resolved output filename of the main
+	// bundle, with any hash placeholder already substituted
+	bundleName string
+}
+
+// loadFile picks a loader based on the file extension, everything
+// that is not js or css gets copied to the bundle directory and
+// imported as a url. Assets skip the cache since they have to be
+// copied into the bundle directory on every build anyway
+func (b *bundle) loadFile(path string) (jsLoader.FileData, error) {
+	var result jsLoader.FileData
+	var err error
+
+	// the module key may carry an import query, the file on disk
+	// does not. Modules with a query skip the cache since the query
+	// is not part of the cache's stat key
+	filePath, query := jsLoader.SplitQuery(path)
+
+	switch filepath.Ext(filePath) {
+	case ".js", ".jsx", ".ts":
+		if query == "" {
+			if data, ok := b.cache.Get(path); ok {
+				return data, nil
+			}
+		}
+		var src []byte
+		src, err = os.ReadFile(filePath)
+		if err == nil {
+			result, err = jsLoader.LoadFile(src, path)
+		}
+
+	case ".css":
+		if query == "" {
+			if data, ok := b.cache.Get(path); ok {
+				return data, nil
+			}
+		}
+		var src []byte
+		src, err = os.ReadFile(filePath)
+		if err == nil {
+			result, err = cssLoader.LoadFile(src, path)
+		}
+
+	default:
+		return b.copyFileToAssets(path)
+	}
+
+	if err != nil {
+		return result, err
+	}
+	if query == "" {
+		b.cache.Put(path, result)
+	}
+	return result, nil
+}
+
+// copyFileToAssets copies any non-js file into the bundle
+// directory under a content-hashed name and makes a module whose
+// default export is its url. An asset imported with ?inline is
+// embedded as a data uri instead of being copied
+func (b *bundle) copyFileToAssets(path string) (jsLoader.FileData, error) {
+	result := jsLoader.FileData{}
+	filePath, query := jsLoader.SplitQuery(path)
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return result, err
+	}
+
+	moduleName := jsLoader.CreateVarNameFromPath(path)
+	if query == "inline" {
+		mimeType := mime.TypeByExtension(filepath.Ext(filePath))
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		uri := "data:" + mimeType + ";base64," +
+			base64.StdEncoding.EncodeToString(content)
+		result.Code = []byte(moduleName + "_default = '" + uri + "';")
+		result.Exports = []string{"default"}
+		return result, nil
+	}
+
+	fileName := loaderUtils.HashedFileName(filePath, content)
+	// in-memory builds have no bundle directory, the module still
+	// exports the url the asset would get
+	if b.config.BundleDir != "" {
+		err = os.WriteFile(filepath.Join(b.config.BundleDir, fileName), content, 0666)
+		if err != nil {
+			return result, err
+		}
+	}
+	b.assetMu.Lock()
+	b.assetManifest[filePath] = fileName
+	b.assetMu.Unlock()
+
+	result.Code = []byte(moduleName + "_default = '" + fileName + "';")
+	result.Exports = []string{"default"}
+	return result, nil
+}
+
+// maxLoadWorkers bounds how many files are parsed at the same time
+var maxLoadWorkers = runtime.NumCPU()
+
+// loadFiles loads a file and everything it imports, parsing one
+// dependency level at a time with a bounded pool of parallel
+// workers. It returns the set of files that were not already part
+// of the bundle
+func (b *bundle) loadFiles(entry string) (map[string]bool, error) {
+	newFiles := map[string]bool{}
+	frontier := []string{}
+	if _, visited := b.files[entry]; !visited {
+		b.files[entry] = nil
+		newFiles[entry] = true
+		frontier = append(frontier, entry)
+	}
+
+	for len(frontier) > 0 {
+		results := make([]jsLoader.FileData, len(frontier))
+		errs := make([]error, len(frontier))
+		sem := make(chan bool, maxLoadWorkers)
+		var wg sync.WaitGroup
+		for i, path := range frontier {
+			wg.Add(1)
+			go func(i int, path string) {
+				defer wg.Done()
+				sem <- true
+				defer func() { <-sem }()
+				results[i], errs[i] = b.loadFile(path)
+			}(i, path)
+		}
+		wg.Wait()
+
+		failed := buildError{}
+		next := []string{}
+		for i, path := range frontier {
+			if errs[i] != nil {
+				failed = append(failed, fmt.Errorf("%s: %w", path, errs[i]))
+				continue
+			}
+			data := results[i]
+			b.files[path] = &data
+			for _, imp := range data.Imports {
+				if _, visited := b.files[imp]; visited {
+					continue
+				}
+				b.files[imp] = nil
+				newFiles[imp] = true
+				next = append(next, imp)
+			}
+		}
+		if len(failed) > 0 {
+			return nil, failed
+		}
+		frontier = next
+	}
+	return newFiles, nil
+}
+
+// buildError collects every file that failed to load, so one build
+// reports all broken files instead of stopping at the first
+type buildError []error
+
+func (e buildError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// module states while orderModules walks the graph
+const (
+	orderPending = iota + 1
+	orderDone
+)
+
+// orderModules fills moduleOrder with a stable dependency-first
+// order of the newly loaded files, recording circular imports on
+// the way. The order only depends on the import graph, so two
+// builds of unchanged sources produce identical bundles no matter
+// how the parallel load interleaved
+func (b *bundle) orderModules(path string, newFiles map[string]bool, state map[string]int) {
+	if !newFiles[path] || state[path] == orderDone {
+		return
+	}
+	state[path] = orderPending
+	for _, imp := range b.files[path].Imports {
+		if state[imp] == orderPending {
+			b.cycles[path] = append(b.cycles[path], imp)
+			continue
+		}
+		b.orderModules(imp, newFiles, state)
+	}
+	state[path] = orderDone
+	b.moduleOrder = append(b.moduleOrder, path)
+}
+
+// addFileToBundle loads a file and everything it imports, filling
+// moduleOrder so that dependencies come before the files that
+// import them
+func (b *bundle) addFileToBundle(path string) error {
+	newFiles, err := b.loadFiles(path)
+	if err != nil {
+		return err
+	}
+	b.orderModules(path, newFiles, map[string]int{})
+	return nil
+}
+
+// shakeUnusedExports retransforms modules whose named exports are
+// never imported anywhere, dropping the dead export assignments.
+// Default exports, star re-exports and namespace imports are left
+// alone: anything reachable through them counts as used
+func (b *bundle) shakeUnusedExports() {
+	used := map[string]map[string]bool{}
+	mark := func(path, name string) {
+		if used[path] == nil {
+			used[path] = map[string]bool{}
+		}
+		used[path][name] = true
+	}
+
+	for _, data := range b.files {
+		for imp, names := range data.ImportedNames {
+			for _, name := range names {
+				mark(imp, name)
+			}
+		}
+		for _, ns := range data.NamespaceImports {
+			mark(ns, "*")
+		}
+		for _, star := range data.StarReexports {
+			mark(star, "*")
+		}
+	}
+
+	for _, path := range b.moduleOrder {
+		if ext := filepath.Ext(path); ext != ".js" && ext != ".jsx" && ext != ".ts" {
+			continue
+		}
+		data := b.files[path]
+		if used[path]["*"] {
+			continue
+		}
+
+		dropped := map[string]bool{}
+		for _, exp := range data.Exports {
+			if exp != "default" && !used[path][exp] {
+				dropped[exp] = true
+			}
+		}
+		if len(dropped) == 0 {
+			continue
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		shaken, err := jsLoader.LoadFileWithoutExports(src, path, dropped)
+		if err != nil {
+			continue
+		}
+		data.Code = shaken.Code
+		data.Exports = shaken.Exports
+		data.Mappings = shaken.Mappings
+	}
+}
+
+// processEnvObject emits the process.env object at the top of the
+// bundle so library code branching on NODE_ENV works at runtime,
+// with the values coming from the define config
+func (b *bundle) processEnvObject() string {
+	env := map[string]string{"NODE_ENV": "development"}
+	for name, value := range b.config.Define {
+		env[name] = value
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props := make([]string, 0, len(env))
+	for _, name := range names {
+		props = append(props, name+": "+strconv.Quote(env[name]))
+	}
+	return "var process = {env: {" + strings.Join(props, ", ") + "}};\n"
+}
+
+// getJsBundleFileHead declares every module variable the bundle
+// is going to assign to
+func (b *bundle) getJsBundleFileHead() string {
+	names := []string{}
+	for _, path := range b.moduleOrder {
+		moduleName := jsLoader.CreateVarNameFromPath(path)
+		data := b.files[path]
+		for _, exp := range data.Exports {
+			names = append(names, moduleName+"_"+exp)
+		}
+		for _, ns := range data.NamespaceImports {
+			nsName := jsLoader.CreateVarNameFromPath(ns) + "_namespace"
+			names = append(names, nsName)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return "var " + strings.Join(names, ", ") + ";\n"
+}
+
+// RuntimeTemplate is the glue code wrapping each module and
+// starting the bundle. Everything that emits glue goes through the
+// active template, so replacing it swaps the loading strategy
+// (HMR, lazy evaluation) without touching the generators
+type RuntimeTemplate struct {
+	// ModuleOpen opens the wrapper around a module's code and
+	// initializes its dependencies, imports holds their wrapper
+	// names in initialization order
+	ModuleOpen func(moduleName string, imports []string) string
+	// ModuleClose ends the wrapper, epilogue carries the module's
+	// star-reexport copies and namespace object
+	ModuleClose func(moduleName string, epilogue string) string
+	// Tail starts execution at the entry module
+	Tail func(entryName string) string
+}
+
+// Runtime overrides the default runtime for programmatic builds,
+// nil keeps the default loader
+var Runtime *RuntimeTemplate
+
+// defaultRuntime guards every module function against running
+// twice and runs its dependencies first. A circular import makes
+// the re-entrant call return immediately, so the importing module
+// sees partially-initialized exports the way CommonJS resolves
+// cycles, instead of running modules in a possibly wrong order
+var defaultRuntime = RuntimeTemplate{
+	ModuleOpen: func(moduleName string, imports []string) string {
+		var sb strings.Builder
+		sb.WriteString("function " + moduleName + "() {\n")
+		sb.WriteString("if (" + moduleName + ".hasRun) return;\n")
+		sb.WriteString(moduleName + ".hasRun = true;\n")
+		for _, imp := range imports {
+			sb.WriteString(imp + "();\n")
+		}
+		return sb.String()
+	},
+	ModuleClose: func(moduleName, epilogue string) string {
+		return "\n" + epilogue + "}\n"
+	},
+	Tail: func(entryName string) string {
+		return entryName + "();\n"
+	},
+}
+
+func activeRuntime() RuntimeTemplate {
+	if Runtime != nil {
+		return *Runtime
+	}
+	return defaultRuntime
+}
+
+// importNames lists the wrapper names of a module's imports, the
+// runtime template initializes them before the module body runs
+func (b *bundle) importNames(path string) []string {
+	names := []string{}
+	for _, imp := range b.files[path].Imports {
+		names = append(names, jsLoader.CreateVarNameFromPath(imp))
+	}
+	return names
+}
+
+// namespaceNeeded tells whether some module in the bundle imports
+// this one with a star import
+func (b *bundle) namespaceNeeded(path string) bool {
+	for _, data := range b.files {
+		for _, ns := range data.NamespaceImports {
+			if ns == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moduleEpilogue copies star-reexported names onto this module's
+// export variables and builds the namespace object if some module
+// star imports this one. The reexported modules have already run
+// at this point because the prologue initialized them. Explicit
+// exports of the module shadow star-reexported ones, reexporting
+// the same module twice is a no-op, and when two different star
+// sources provide the same name the first one wins with a warning
+func (b *bundle) moduleEpilogue(path string) string {
+	moduleName := jsLoader.CreateVarNameFromPath(path)
+	data := b.files[path]
+	var sb strings.Builder
+
+	explicit := map[string]bool{}
+	for _, exp := range data.Exports {
+		explicit[exp] = true
+	}
+	seenStar := map[string]bool{}
+	starSource := map[string]string{}
+
+	for _, star := range data.StarReexports {
+		if seenStar[star] {
+			continue
+		}
+		seenStar[star] = true
+		starName := jsLoader.CreateVarNameFromPath(star)
+		for _, exp := range b.files[star].Exports {
+			if exp == "default" || explicit[exp] {
+				continue
+			}
+			if prev, ok := starSource[exp]; ok {
+				fmt.Printf("Warning: %s reexports '%s' from both %s and %s\n",
+					path, exp, prev, star)
+				continue
+			}
+			starSource[exp] = star
+			data.Exports = append(data.Exports, exp)
+			sb.WriteString(moduleName + "_" + exp + " = " + starName + "_" + exp + ";\n")
+		}
+	}
+	if b.namespaceNeeded(path) {
+		sb.WriteString(namespaceObject(moduleName, data.Exports))
+	}
+	return sb.String()
+}
+
+// getJsBundleFileTail starts execution at the entry module, the
+// module prologues pull in everything else on demand
+func (b *bundle) getJsBundleFileTail() string {
+	if len(b.moduleOrder) == 0 {
+		return ""
+	}
+	entry := b.moduleOrder[len(b.moduleOrder)-1]
+	return activeRuntime().Tail(jsLoader.CreateVarNameFromPath(entry))
+}
+
+// entryExports names the entry module and its exported bindings,
+// which become the bundle's own exports in umd and esm output
+func (b *bundle) entryExports() (string, []string) {
+	if len(b.moduleOrder) == 0 {
+		return "", nil
+	}
+	entry := b.moduleOrder[len(b.moduleOrder)-1]
+	return jsLoader.CreateVarNameFromPath(entry), b.files[entry].Exports
+}
+
+// expandBannerPlaceholders substitutes [date] and [version] in a
+// banner or footer. The version comes from the package.json in the
+// working directory, a missing version expands to an empty string
+func expandBannerPlaceholders(text string) string {
+	text = strings.ReplaceAll(text, "[date]", time.Now().Format("2006-01-02"))
+	if strings.Contains(text, "[version]") {
+		version := ""
+		if data, err := os.ReadFile("package.json"); err == nil {
+			var pkg struct {
+				Version string `json:"version"`
+			}
+			if json.Unmarshal(data, &pkg) == nil {
+				version = pkg.Version
+			}
+		}
+		text = strings.ReplaceAll(text, "[version]", version)
+	}
+	return text + "\n"
+}
+
+// formatHead opens the wrapper the configured output format needs,
+// the bundle body itself is format-independent
+func (b *bundle) formatHead() string {
+	switch b.config.Format {
+	case "iife":
+		return "(function() {\n"
+	case "umd":
+		name := b.config.Library
+		if name == "" {
+			name = "bundle"
+		}
+		return "(function(root, factory) {\n" +
+			"if (typeof define === 'function' && define.amd) { define(factory); }\n" +
+			"else if (typeof module === 'object' && module.exports) { module.exports = factory(); }\n" +
+			"else { root." + name + " = factory(); }\n" +
+			"})(typeof self !== 'undefined' ? self : this, function() {\n"
+	}
+	return ""
+}
+
+// formatTail closes the format wrapper. The umd factory returns an
+// object with the entry module's exports, esm re-exports them as
+// native bindings
+func (b *bundle) formatTail() string {
+	switch b.config.Format {
+	case "iife":
+		return "})();\n"
+	case "umd":
+		entryName, exports := b.entryExports()
+		props := []string{}
+		for _, exp := range exports {
+			props = append(props, exp+": "+entryName+"_"+exp)
+		}
+		return "return {" + strings.Join(props, ", ") + "};\n});\n"
+	case "esm":
+		entryName, exports := b.entryExports()
+		var sb strings.Builder
+		for _, exp := range exports {
+			if exp == "default" {
+				sb.WriteString("export default " + entryName + "_default;\n")
+			} else {
+				sb.WriteString("export var " + exp + " = " + entryName + "_" + exp + ";\n")
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// namespaceObject builds the object literal statement holding all
+// of a module's exports
+func namespaceObject(moduleName string, exports []string) string {
+	props := []string{}
+	for _, exp := range exports {
+		props = append(props, exp+": "+moduleName+"_"+exp)
+	}
+	return moduleName + "_namespace = {" + strings.Join(props, ", ") + "};\n"
+}
+
+// chunkRuntime loads a chunk script at most once and resolves to
+// the namespace object of the dynamically imported module
+const chunkRuntime = `var __goBundlerChunks = {};
+function __goBundlerImport(file, getModule) {
+if (!__goBundlerChunks[file]) {
+__goBundlerChunks[file] = new Promise(function(resolve, reject) {
+var script = document.createElement('script');
+script.src = file;
+script.onload = resolve;
+script.onerror = reject;
+document.head.appendChild(script);
+});
+}
+return __goBundlerChunks[file].then(getModule);
+}
+`
+
+// dynamicImports collects every dynamically imported path in the
+// bundle
+func (b *bundle) dynamicImports() []string {
+	paths := []string{}
+	for _, path := range b.moduleOrder {
+		paths = append(paths, b.files[path].DynamicImports...)
+	}
+	return paths
+}
+
+// writeChunks emits one chunk file per dynamically imported
+// module, containing the imported subtree minus everything the
+// main bundle already has. Chunks loading other chunks dynamically
+// are processed too
+func (b *bundle) writeChunks() error {
+	pending := b.dynamicImports()
+	written := map[string]bool{}
+
+	for len(pending) > 0 {
+		path := pending[0]
+		pending = pending[1:]
+		if written[path] {
+			continue
+		}
+		written[path] = true
+
+		chunk := newBundle(b.config, b.cache)
+		chunk.assetManifest = b.assetManifest
+		// modules of the main bundle count as visited so the chunk
+		// only contains what the page has not loaded yet
+		for loaded, data := range b.files {
+			chunk.files[loaded] = data
+		}
+
+		if err := chunk.addFileToBundle(path); err != nil {
+			return err
+		}
+		pending = append(pending, chunk.dynamicImports()...)
+		b.collectCSS(chunk)
+
+		var sb strings.Builder
+		sb.WriteString(chunk.getJsBundleFileHead())
+
+		entryName := jsLoader.CreateVarNameFromPath(path)
+		sb.WriteString("var " + entryName + "_namespace;\n")
+
+		runtime := activeRuntime()
+		for _, modPath := range chunk.moduleOrder {
+			moduleName := jsLoader.CreateVarNameFromPath(modPath)
+			sb.WriteString(runtime.ModuleOpen(moduleName, chunk.importNames(modPath)))
+			sb.Write(chunk.files[modPath].Code)
+			sb.WriteString(runtime.ModuleClose(moduleName, chunk.moduleEpilogue(modPath)))
+		}
+
+		// namespace objects for main-bundle modules the chunk star
+		// imports may not have been built by the main bundle
+		inChunk := map[string]bool{}
+		for _, modPath := range chunk.moduleOrder {
+			inChunk[modPath] = true
+		}
+		for _, modPath := range chunk.moduleOrder {
+			for _, ns := range chunk.files[modPath].NamespaceImports {
+				if !inChunk[ns] {
+					sb.WriteString(namespaceObject(
+						jsLoader.CreateVarNameFromPath(ns), chunk.files[ns].Exports))
+				}
+			}
+		}
+
+		sb.WriteString(chunk.getJsBundleFileTail())
+		sb.WriteString(namespaceObject(entryName, chunk.files[path].Exports))
+
+		chunkPath := filepath.Join(b.config.BundleDir, jsLoader.ChunkFileName(path))
+		err := os.WriteFile(chunkPath, []byte(sb.String()), 0666)
+		if err != nil {
+			return err
+		}
+		if err := b.writeCompressedVariants(chunkPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCompressedVariants writes pre-compressed copies next to a
+// written output file, so a static server can serve them directly.
+// They regenerate on every build and never fall behind the file
+// they mirror. validateConfig only lets gzip through
+func (b *bundle) writeCompressedVariants(path string) error {
+	for _, algo := range b.config.Compress {
+		if algo != "gzip" {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		writer, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := writer.Write(content); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+".gz", buf.Bytes(), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectCSS gathers the stylesheet text the css loader extracted,
+// in module order so the cascade matches the order styles would
+// have been injected at runtime
+func (b *bundle) collectCSS(from *bundle) {
+	if b.cssSeen == nil {
+		b.cssSeen = map[string]bool{}
+	}
+	for _, path := range from.moduleOrder {
+		if b.cssSeen[path] {
+			continue
+		}
+		b.cssSeen[path] = true
+		if css := from.files[path].CSS; len(css) > 0 {
+			b.css = append(b.css, css...)
+			b.css = append(b.css, '\n')
+		}
+	}
+}
+
+// printModuleOrder writes the computed module initialization
+// order in resolved-path form, marking circular imports inline
+func (b *bundle) printModuleOrder(out io.Writer) {
+	fmt.Fprintln(out, "Module order:")
+	for i, path := range b.moduleOrder {
+		fmt.Fprintf(out, "%3d. %s", i+1, path)
+		if cycleImports, ok := b.cycles[path]; ok {
+			fmt.Fprintf(out, " (cycle with %s)", strings.Join(cycleImports, ", "))
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func newBundle(config *configJSON, cache *bundleCache.Cache) *bundle {
+	return &bundle{
+		files:         map[string]*jsLoader.FileData{},
+		config:        config,
+		cache:         cache,
+		cycles:        map[string][]string{},
+		assetManifest: map[string]string{},
+	}
+}
+
+// build loads the whole dependency graph starting from the entry
+// file
+func (b *bundle) build() error {
+	err := b.addFileToBundle(b.config.Entry)
+	if err != nil {
+		return err
+	}
+	if b.config.TreeShake {
+		b.shakeUnusedExports()
+	}
+	return nil
+}
+
+// generateTo streams the assembled bundle into w. The source map,
+// when enabled, ends up in b.sourceMap since it goes to a separate
+// destination than the code
+func (b *bundle) generateTo(w io.Writer) error {
+	line := 0
+	var writeErr error
+	write := func(s string) {
+		if writeErr != nil {
+			return
+		}
+		_, writeErr = io.WriteString(w, s)
+		line += strings.Count(s, "\n")
+	}
+	sourceMap := sourceMapBuilder{}
+
+	if b.config.Banner != "" {
+		write(expandBannerPlaceholders(b.config.Banner))
+	}
+	write(b.formatHead())
+	write(b.processEnvObject())
+	if len(b.dynamicImports()) > 0 {
+		write(chunkRuntime)
+	}
+	write(b.getJsBundleFileHead())
+
+	runtime := activeRuntime()
+	for _, path := range b.moduleOrder {
+		moduleName := jsLoader.CreateVarNameFromPath(path)
+		write(runtime.ModuleOpen(moduleName, b.importNames(path)))
+		sourceMap.addModule(path, b.files[path].Mappings, line)
+		write(string(b.files[path].Code))
+		write(runtime.ModuleClose(moduleName, b.moduleEpilogue(path)))
+	}
+
+	write(b.getJsBundleFileTail())
+
+	if b.config.DevServer.Enable {
+		write(reloadClientRuntime)
+	}
+	write(b.formatTail())
+	if b.config.Footer != "" {
+		write(expandBannerPlaceholders(b.config.Footer))
+	}
+
+	if b.config.SourceMaps {
+		name := b.bundleName
+		if name == "" {
+			name = "bundle.js"
+		}
+		write("//# sourceMappingURL=" + name + ".map\n")
+		b.sourceMap = sourceMap.build()
+	}
+
+	return writeErr
+}
+
+// generate assembles the bundle in memory and returns it together
+// with the source map, which is nil unless source maps are enabled
+func (b *bundle) generate() ([]byte, []byte) {
+	var sb strings.Builder
+	b.generateTo(&sb)
+	return []byte(sb.String()), b.sourceMap
+}
+
+// createBundleTo builds the dependency graph and streams the
+// bundle into any writer, e.g. a compressor or an http response
+func createBundleTo(w io.Writer, config *configJSON, cache *bundleCache.Cache) (*bundle, error) {
+	b := newBundle(config, cache)
+	if err := b.build(); err != nil {
+		return b, err
+	}
+	return b, b.generateTo(w)
+}
+
+// createBundle builds the whole dependency graph starting from
+// the entry file and writes the bundle to the bundle directory
+func createBundle(config *configJSON, cache *bundleCache.Cache) (*bundle, error) {
+	b := newBundle(config, cache)
+
+	err := os.MkdirAll(config.BundleDir, 0755)
+	if err != nil {
+		return b, err
+	}
+
+	err = b.build()
+	if err != nil {
+		return b, err
+	}
+	return b, b.writeOutputs()
+}
+
+// rebuild refreshes the bundle in place after the given files
+// changed on disk. Only those files are re-loaded — the cache
+// notices their new content — together with any imports they
+// gained, then the module order is recomputed over the new graph
+// and files nothing imports anymore drop out. Everything else is
+// reused as loaded
+func (b *bundle) rebuild(changed []string) error {
+	for _, path := range changed {
+		delete(b.files, path)
+	}
+
+	failed := buildError{}
+	for _, path := range changed {
+		if _, err := b.loadFiles(path); err != nil {
+			if errs, ok := err.(buildError); ok {
+				failed = append(failed, errs...)
+			} else {
+				failed = append(failed, err)
+			}
+		}
+	}
+	// placeholders of files that failed to load would count as
+	// visited on the next attempt, so they go too
+	for path, data := range b.files {
+		if data == nil {
+			delete(b.files, path)
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+
+	// a changed file may import less than before, walk the new
+	// graph and drop what became unreachable
+	reachable := map[string]bool{}
+	var walk func(path string)
+	walk = func(path string) {
+		if reachable[path] || b.files[path] == nil {
+			return
+		}
+		reachable[path] = true
+		for _, imp := range b.files[path].Imports {
+			walk(imp)
+		}
+	}
+	walk(b.config.Entry)
+	for path := range b.files {
+		if !reachable[path] {
+			delete(b.files, path)
+		}
+	}
+
+	b.moduleOrder = nil
+	b.cycles = map[string][]string{}
+	b.orderModules(b.config.Entry, reachable, map[string]int{})
+	return nil
+}
+
+// writeOutputs writes the assembled bundle and everything that
+// belongs next to it — source map, chunks, css, stats, html,
+// manifest — into the bundle directory
+func (b *bundle) writeOutputs() error {
+	config := b.config
+	b.css = nil
+	b.cssSeen = nil
+
+	bundleName := config.BundleName
+	if bundleName == "" {
+		bundleName = "bundle.js"
+	}
+	b.bundleName = bundleName
+	if strings.Contains(bundleName, "[hash]") || strings.Contains(bundleName, "[contenthash]") {
+		// the content references its own name in the source map
+		// comment, so hash a first pass and generate again with the
+		// final name substituted
+		var buf strings.Builder
+		if err := b.generateTo(&buf); err != nil {
+			return err
+		}
+		sum := sha1.Sum([]byte(buf.String()))
+		hash := hex.EncodeToString(sum[:])[:8]
+		bundleName = strings.ReplaceAll(bundleName, "[contenthash]", hash)
+		bundleName = strings.ReplaceAll(bundleName, "[hash]", hash)
+		b.bundleName = bundleName
+	}
+
+	bundlePath := filepath.Join(config.BundleDir, bundleName)
+	out, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	err = b.generateTo(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = b.writeCompressedVariants(bundlePath); err != nil {
+		return err
+	}
+
+	if b.sourceMap != nil {
+		err = os.WriteFile(bundlePath+".map", b.sourceMap, 0666)
+		if err != nil {
+			return err
+		}
+	}
+
+	b.collectCSS(b)
+	err = b.writeChunks()
+	if err != nil {
+		return err
+	}
+
+	cssName := ""
+	if len(b.css) > 0 {
+		cssName = "bundle.css"
+		err = os.WriteFile(filepath.Join(config.BundleDir, cssName), b.css, 0666)
+		if err != nil {
+			return err
+		}
+		if err = b.writeCompressedVariants(filepath.Join(config.BundleDir, cssName)); err != nil {
+			return err
+		}
+	}
+
+	if config.Stats {
+		err = b.writeStats()
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.TemplateHTML != "" {
+		assets := []htmlAsset{}
+		if cssName != "" {
+			assets = append(assets, htmlAsset{
+				tag:    "<link rel=\"stylesheet\" href=\"" + cssName + "\">\n",
+				inHead: true,
+			})
+		}
+		assets = append(assets, htmlAsset{tag: "<script src=\"" + bundleName + "\"></script>\n"})
+		err = writeBundleHTML(config, assets)
+		if err != nil {
+			return err
+		}
+	}
+
+	if bundleName != "bundle.js" {
+		b.assetManifest["bundle.js"] = bundleName
+	}
+	if config.PublicDir != "" {
+		err = copyPublicDir(config.PublicDir, config.BundleDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Manifest.Enable {
+		manifestPath := config.Manifest.Path
+		if manifestPath == "" {
+			manifestPath = filepath.Join(config.BundleDir, "manifest.json")
+		}
+		manifest, _ := json.MarshalIndent(b.assetManifest, "", "  ")
+		err = os.WriteFile(manifestPath, manifest, 0666)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyPublicDir copies static files that no module imports
+// (favicon.ico, robots.txt) into the bundle directory, keeping the
+// subdirectory structure. A file that would overwrite something the
+// build generated is skipped with a warning
+func copyPublicDir(publicDir, bundleDir string) error {
+	return filepath.Walk(publicDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(publicDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(bundleDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("Warning: public file %s would overwrite a generated file, skipping\n", rel)
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0666)
+	})
+}
+
+// moduleStats is one entry of the stats.json report
+type moduleStats struct {
+	Path    string   `json:"path"`
+	Size    int      `json:"size"`
+	Imports []string `json:"imports"`
+}
+
+// writeStats reports every included module with its size in the
+// output and its imports, for bundle-analyzer style tooling
+func (b *bundle) writeStats() error {
+	stats := []moduleStats{}
+	for _, path := range b.moduleOrder {
+		data := b.files[path]
+		imports := data.Imports
+		if imports == nil {
+			imports = []string{}
+		}
+		stats = append(stats, moduleStats{
+			Path:    path,
+			Size:    len(data.Code),
+			Imports: imports,
+		})
+	}
+
+	report, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.config.BundleDir, "stats.json"), report, 0666)
+}
+
+// injectBefore inserts snippet right before the first closing tag
+// with the given name. The tag matches case-insensitively and may
+// have whitespace before the '>', which minified or hand-written
+// templates often do. Reports whether the tag was found
+func injectBefore(html, tagName, snippet string) (string, bool) {
+	lower := strings.ToLower(html)
+	marker := "</" + tagName
+	from := 0
+	for {
+		i := strings.Index(lower[from:], marker)
+		if i < 0 {
+			return html, false
+		}
+		i += from
+		j := i + len(marker)
+		for j < len(lower) && (lower[j] == ' ' || lower[j] == '\t' ||
+			lower[j] == '\n' || lower[j] == '\r') {
+			j++
+		}
+		if j < len(lower) && lower[j] == '>' {
+			return html[:i] + snippet + html[i:], true
+		}
+		from = i + len(marker)
+	}
+}
+
+// htmlAsset is a generated file the html template has to
+// reference: stylesheets go into the head, scripts right before
+// the body closes
+type htmlAsset struct {
+	tag    string
+	inHead bool
+}
+
+// writeBundleHTML injects the tags for the generated assets into
+// the html template and writes it next to the bundle. Head assets
+// go right before </head>; body assets before </body>, before
+// </html> when the template has no body closing tag, or at the end
+// of the file as a last resort. Head assets fall back to the body
+// position when there is no head
+func writeBundleHTML(config *configJSON, assets []htmlAsset) error {
+	template, err := os.ReadFile(config.TemplateHTML)
+	if err != nil {
+		return err
+	}
+
+	html := string(template)
+	injectAtBody := func(snippet string) {
+		var ok bool
+		html, ok = injectBefore(html, "body", snippet)
+		if !ok {
+			html, ok = injectBefore(html, "html", snippet)
+		}
+		if !ok {
+			html += snippet
+		}
+	}
+	for _, asset := range assets {
+		if asset.inHead {
+			if withHead, ok := injectBefore(html, "head", asset.tag); ok {
+				html = withHead
+				continue
+			}
+		}
+		injectAtBody(asset.tag)
+	}
+
+	return os.WriteFile(filepath.Join(config.BundleDir, "index.html"), []byte(html), 0666)
+}
@@ -0,0 +1,189 @@
+package cssLoader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lvl5hm/go-bundler/loaderUtils"
+)
+
+// LoaderOptions controls how css files are processed for the
+// current build
+type LoaderOptions struct {
+	// BundleImports makes the loader follow @import rules and
+	// url() references, adding them to the dependency graph
+	BundleImports bool
+	// PublicPath is prepended to rewritten url() references so
+	// they point at the assets' location in the bundle directory
+	PublicPath string
+	// Extract puts the css text into FileData.CSS for the bundler
+	// to collect into a css bundle, instead of generating js that
+	// injects a <style> tag at runtime
+	Extract bool
+}
+
+// Options holds the css loader options for the current build
+var Options LoaderOptions
+
+type cssImportScanner struct {
+	source  []rune
+	pos     int
+	out     strings.Builder
+	fileDir string
+	imports []string
+}
+
+func (s *cssImportScanner) at(offset int) rune {
+	if s.pos+offset >= len(s.source) {
+		return 0
+	}
+	return s.source[s.pos+offset]
+}
+
+func (s *cssImportScanner) copyRune() {
+	s.out.WriteRune(s.at(0))
+	s.pos++
+}
+
+func (s *cssImportScanner) startsWith(prefix string) bool {
+	for i, c := range prefix {
+		if s.at(i) != c {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *cssImportScanner) copyComment() {
+	s.copyRune()
+	s.copyRune()
+	for s.at(0) != 0 && !(s.at(0) == '*' && s.at(1) == '/') {
+		s.copyRune()
+	}
+	s.copyRune()
+	s.copyRune()
+}
+
+// readRef reads a quoted or bare reference up to the given
+// terminator, e.g. the inside of url(...) or an @import string
+func (s *cssImportScanner) readRef(end rune) string {
+	ref := ""
+	quote := rune(0)
+	if s.at(0) == '"' || s.at(0) == '\'' {
+		quote = s.at(0)
+		s.pos++
+		for s.at(0) != 0 && s.at(0) != quote {
+			ref += string(s.at(0))
+			s.pos++
+		}
+		s.pos++
+	} else {
+		for s.at(0) != 0 && s.at(0) != end {
+			ref += string(s.at(0))
+			s.pos++
+		}
+	}
+	return strings.TrimSpace(ref)
+}
+
+// isRelativeRef tells whether a url reference points at a local
+// file rather than an external or absolute resource
+func isRelativeRef(ref string) bool {
+	return strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "../")
+}
+
+// parseImportRule consumes a whole '@import ...;' rule and
+// returns the referenced path, if it points at a local file
+func (s *cssImportScanner) parseImportRule() (string, bool) {
+	s.pos += len("@import")
+	for s.at(0) == ' ' || s.at(0) == '\t' {
+		s.pos++
+	}
+
+	var ref string
+	if s.startsWith("url(") {
+		s.pos += len("url(")
+		ref = s.readRef(')')
+	} else {
+		ref = s.readRef(';')
+	}
+
+	// skip the rest of the rule including media queries
+	for s.at(0) != 0 && s.at(0) != ';' {
+		s.pos++
+	}
+	if s.at(0) == ';' {
+		s.pos++
+	}
+	for s.at(0) == '\n' {
+		s.pos++
+	}
+
+	if !isRelativeRef(ref) {
+		return "", false
+	}
+	return filepath.Join(s.fileDir, ref), true
+}
+
+// parseUrlRef rewrites a 'url(...)' reference to the asset's
+// location in the bundle and records the asset as an import
+func (s *cssImportScanner) parseUrlRef() {
+	s.pos += len("url(")
+	ref := s.readRef(')')
+	if s.at(0) == ')' {
+		s.pos++
+	}
+
+	if !isRelativeRef(ref) {
+		s.out.WriteString("url(" + ref + ")")
+		return
+	}
+
+	resolved := filepath.Join(s.fileDir, ref)
+	s.imports = append(s.imports, resolved)
+
+	// the bundler copies assets under content-hashed names, use
+	// the same name here so the reference stays valid
+	name := filepath.Base(resolved)
+	if content, err := os.ReadFile(resolved); err == nil {
+		name = loaderUtils.HashedFileName(resolved, content)
+	}
+	s.out.WriteString("url(" + Options.PublicPath + name + ")")
+}
+
+func (s *cssImportScanner) scan() {
+	for s.at(0) != 0 {
+		switch {
+		case s.at(0) == '/' && s.at(1) == '*':
+			s.copyComment()
+
+		case s.startsWith("@import"):
+			start := s.pos
+			if path, ok := s.parseImportRule(); ok {
+				s.imports = append(s.imports, path)
+			} else {
+				// external imports stay in the css untouched
+				s.out.WriteString(string(s.source[start:s.pos]))
+			}
+
+		case s.startsWith("url("):
+			s.parseUrlRef()
+
+		default:
+			s.copyRune()
+		}
+	}
+}
+
+// transformCssImports strips local @import rules from a css file,
+// returning them as dependencies, and rewrites relative url()
+// references to point into the bundle directory
+func transformCssImports(src []byte, filePath string) (string, []string) {
+	s := cssImportScanner{
+		source:  []rune(string(src)),
+		fileDir: filepath.Dir(filePath),
+	}
+	s.scan()
+	return s.out.String(), s.imports
+}
@@ -0,0 +1,55 @@
+package cssLoader
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+// LoadFile turns a css file into a js module that appends its
+// contents to the document head in a <style> tag. Files named
+// '*.module.css' additionally get css modules treatment: class
+// selectors are hashed and the mapping from original names
+// becomes the default export
+func LoadFile(src []byte, filePath string) (jsLoader.FileData, error) {
+	result := jsLoader.FileData{}
+
+	css := string(src)
+	if Options.BundleImports {
+		transformed, imports := transformCssImports(src, filePath)
+		css = transformed
+		result.Imports = imports
+	}
+
+	exportObject := ""
+	if strings.HasSuffix(filePath, ".module.css") {
+		transformed, mapping := transformCssModule([]byte(css), filePath)
+		css = transformed
+		exportObject = cssModuleExportObject(mapping)
+	}
+
+	if Options.Extract {
+		result.CSS = []byte(css)
+		if exportObject != "" {
+			moduleName := jsLoader.CreateVarNameFromPath(filePath)
+			result.Code = []byte(moduleName + "_default = " + exportObject + ";")
+			result.Exports = []string{"default"}
+		}
+		return result, nil
+	}
+
+	js := "var style = document.createElement('style');\n" +
+		"style.type = 'text/css';\n" +
+		"style.appendChild(document.createTextNode(" + strconv.Quote(css) + "));\n" +
+		"document.head.appendChild(style);"
+
+	if exportObject != "" {
+		moduleName := jsLoader.CreateVarNameFromPath(filePath)
+		js += "\n" + moduleName + "_default = " + exportObject + ";"
+		result.Exports = []string{"default"}
+	}
+
+	result.Code = []byte(js)
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package cssLoader
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCssInjectsStyleTag(t *testing.T) {
+	src := []byte(".a { color: red; }")
+	data, err := LoadFile(src, "styles.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := string(data.Code)
+	if !strings.Contains(code, "document.createElement('style')") ||
+		!strings.Contains(code, "document.head.appendChild(style)") {
+		t.Errorf("expected style injection code, got:\n%s", code)
+	}
+	if !strings.Contains(code, ".a { color: red; }") {
+		t.Errorf("expected css content embedded in the module, got:\n%s", code)
+	}
+	// a plain stylesheet import is a side effect only, no exports
+	if len(data.Exports) != 0 {
+		t.Errorf("plain css should not export anything, got %v", data.Exports)
+	}
+}
+
+func TestCssExtract(t *testing.T) {
+	defer func() { Options = LoaderOptions{} }()
+	Options = LoaderOptions{Extract: true}
+
+	data, err := LoadFile([]byte(".a { color: red; }"), "styles.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data.CSS) != ".a { color: red; }" {
+		t.Errorf("expected the css text in FileData.CSS, got %q", data.CSS)
+	}
+	if strings.Contains(string(data.Code), "document.createElement") {
+		t.Errorf("expected no runtime style injection, got:\n%s", data.Code)
+	}
+
+	// css modules still export their class name mapping
+	data, err = LoadFile([]byte(".btn { color: red; }"), "a.module.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Exports) != 1 || data.Exports[0] != "default" {
+		t.Errorf("expected a default export with the mapping, got %v", data.Exports)
+	}
+	if !strings.Contains(string(data.Code), "_default = ") {
+		t.Errorf("expected the mapping assignment, got:\n%s", data.Code)
+	}
+}
+
+func TestCssImportRule(t *testing.T) {
+	defer func() { Options = LoaderOptions{} }()
+	Options = LoaderOptions{BundleImports: true}
+
+	src := []byte("@import './other.css';\n.a { color: red; }\n")
+	data, err := LoadFile(src, filepath.Join("styles", "main.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join("styles", "other.css")
+	if len(data.Imports) != 1 || data.Imports[0] != expected {
+		t.Errorf("expected import of %s, got %v", expected, data.Imports)
+	}
+	if strings.Contains(string(data.Code), "@import") {
+		t.Errorf("@import rule should be stripped from the css:\n%s", data.Code)
+	}
+}
+
+func TestCssNestedImports(t *testing.T) {
+	defer func() { Options = LoaderOptions{} }()
+	Options = LoaderOptions{BundleImports: true}
+
+	// each file's imports resolve relative to that file, which is
+	// how nested imports end up in the graph
+	outer, err := LoadFile([]byte("@import './nested/inner.css';"),
+		filepath.Join("styles", "outer.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerPath := outer.Imports[0]
+	if innerPath != filepath.Join("styles", "nested", "inner.css") {
+		t.Fatalf("unexpected inner path %s", innerPath)
+	}
+
+	inner, err := LoadFile([]byte("@import '../base.css';\n.b {}"), innerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.Imports) != 1 || inner.Imports[0] != filepath.Join("styles", "base.css") {
+		t.Errorf("expected nested import of styles/base.css, got %v", inner.Imports)
+	}
+}
+
+func TestCssUrlRewriting(t *testing.T) {
+	defer func() { Options = LoaderOptions{} }()
+	Options = LoaderOptions{BundleImports: true, PublicPath: "assets/"}
+
+	src := []byte(".a { background: url('./img/bg.png'); }\n" +
+		".b { background: url(https://example.com/x.png); }\n")
+	data, err := LoadFile(src, filepath.Join("styles", "main.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join("styles", "img", "bg.png")
+	if len(data.Imports) != 1 || data.Imports[0] != expected {
+		t.Errorf("expected asset import of %s, got %v", expected, data.Imports)
+	}
+
+	code := string(data.Code)
+	if !strings.Contains(code, "url(assets/bg.png)") {
+		t.Errorf("expected rewritten url with public path, got:\n%s", code)
+	}
+	if !strings.Contains(code, "url(https://example.com/x.png)") {
+		t.Errorf("external url should be untouched, got:\n%s", code)
+	}
+}
+
+func TestExternalImportUntouched(t *testing.T) {
+	defer func() { Options = LoaderOptions{} }()
+	Options = LoaderOptions{BundleImports: true}
+
+	src := []byte("@import url(https://fonts.example.com/x.css);\n.a {}")
+	data, err := LoadFile(src, "main.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Imports) != 0 {
+		t.Errorf("external import should not be bundled, got %v", data.Imports)
+	}
+	if !strings.Contains(string(data.Code), "@import url(https://fonts.example.com/x.css)") {
+		t.Errorf("external @import should stay in the css:\n%s", data.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package cssLoader
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// hashClassName makes a stable unique class name from the file
+// path and the original name, so rebuilding does not change names
+func hashClassName(filePath, name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(filePath + ":" + name))
+	return name + "_" + fmt.Sprintf("%08x", h.Sum32())
+}
+
+type cssModuleParser struct {
+	source   []rune
+	pos      int
+	out      strings.Builder
+	filePath string
+	classes  map[string]string
+	// composed maps a local class to extra classes added through
+	// 'composes:' declarations
+	composed map[string][]string
+	// classes mentioned in the selector of the block we are
+	// currently inside, needed to attach 'composes:' results
+	currentClasses []string
+	// blockStack remembers for every open '{' whether it holds
+	// nested rules (@media and friends) or declarations
+	blockStack []bool
+}
+
+func (p *cssModuleParser) at(offset int) rune {
+	if p.pos+offset >= len(p.source) {
+		return 0
+	}
+	return p.source[p.pos+offset]
+}
+
+func (p *cssModuleParser) copyRune() {
+	p.out.WriteRune(p.at(0))
+	p.pos++
+}
+
+func isClassNameChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') || c == '-' || c == '_'
+}
+
+// inSelectorContext is true at the top level and directly inside
+// container at-rules like @media, where class selectors can appear
+func (p *cssModuleParser) inSelectorContext() bool {
+	for i := len(p.blockStack) - 1; i >= 0; i-- {
+		return p.blockStack[i]
+	}
+	return true
+}
+
+func (p *cssModuleParser) readName() string {
+	name := ""
+	for isClassNameChar(p.at(0)) {
+		name += string(p.at(0))
+		p.pos++
+	}
+	return name
+}
+
+func (p *cssModuleParser) hashAndRecord(name string) string {
+	hashed := hashClassName(p.filePath, name)
+	p.classes[name] = hashed
+	return hashed
+}
+
+func (p *cssModuleParser) copyComment() {
+	p.copyRune()
+	p.copyRune()
+	for p.at(0) != 0 && !(p.at(0) == '*' && p.at(1) == '/') {
+		p.copyRune()
+	}
+	p.copyRune()
+	p.copyRune()
+}
+
+func (p *cssModuleParser) copyString(quote rune) {
+	p.copyRune()
+	for p.at(0) != 0 && p.at(0) != quote {
+		if p.at(0) == '\\' {
+			p.copyRune()
+		}
+		p.copyRune()
+	}
+	p.copyRune()
+}
+
+// copyGlobal strips a ':global(...)' wrapper and copies its
+// selector content verbatim
+func (p *cssModuleParser) copyGlobal() {
+	p.pos += len(":global(")
+	depth := 1
+	for p.at(0) != 0 && depth > 0 {
+		if p.at(0) == '(' {
+			depth++
+		}
+		if p.at(0) == ')' {
+			depth--
+			if depth == 0 {
+				p.pos++
+				break
+			}
+		}
+		p.copyRune()
+	}
+}
+
+// parseComposes consumes a 'composes: a b;' declaration and
+// attaches the referenced classes to the current selector classes
+func (p *cssModuleParser) parseComposes() {
+	p.pos += len("composes")
+	for p.at(0) == ' ' || p.at(0) == '\t' || p.at(0) == ':' {
+		p.pos++
+	}
+	names := []string{}
+	for p.at(0) != 0 && p.at(0) != ';' && p.at(0) != '}' {
+		if isClassNameChar(p.at(0)) {
+			names = append(names, p.readName())
+		} else {
+			p.pos++
+		}
+	}
+	if p.at(0) == ';' {
+		p.pos++
+	}
+	for _, owner := range p.currentClasses {
+		for _, name := range names {
+			p.composed[owner] = append(p.composed[owner], p.hashAndRecord(name))
+		}
+	}
+}
+
+func (p *cssModuleParser) startsWith(s string) bool {
+	for i, c := range s {
+		if p.at(i) != c {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *cssModuleParser) parse() {
+	// atRule tracks whether the selector text we are currently
+	// reading started with '@', those blocks (@media, @supports)
+	// contain nested rules instead of declarations
+	atRule := false
+	preambleEmpty := true
+
+	for p.at(0) != 0 {
+		c := p.at(0)
+
+		switch {
+		case c == '/' && p.at(1) == '*':
+			p.copyComment()
+
+		case c == '"' || c == '\'':
+			p.copyString(c)
+
+		case c == '{':
+			container := p.inSelectorContext() && atRule
+			p.blockStack = append(p.blockStack, container)
+			atRule = false
+			preambleEmpty = true
+			p.copyRune()
+
+		case c == '}':
+			if len(p.blockStack) > 0 {
+				p.blockStack = p.blockStack[:len(p.blockStack)-1]
+			}
+			if p.inSelectorContext() {
+				p.currentClasses = nil
+			}
+			atRule = false
+			preambleEmpty = true
+			p.copyRune()
+
+		case c == ';':
+			atRule = false
+			preambleEmpty = true
+			p.copyRune()
+
+		case p.inSelectorContext() && p.startsWith(":global("):
+			preambleEmpty = false
+			p.copyGlobal()
+
+		case p.inSelectorContext() && c == '.' && isClassNameChar(p.at(1)):
+			preambleEmpty = false
+			p.pos++
+			name := p.readName()
+			p.out.WriteString("." + p.hashAndRecord(name))
+			p.currentClasses = append(p.currentClasses, name)
+
+		case !p.inSelectorContext() && p.startsWith("composes") &&
+			!isClassNameChar(p.at(len("composes"))):
+			p.parseComposes()
+
+		default:
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				if preambleEmpty {
+					atRule = c == '@'
+				}
+				preambleEmpty = false
+			}
+			p.copyRune()
+		}
+	}
+}
+
+// transformCssModule rewrites every local class selector in a
+// '.module.css' file to a hashed name and returns the mapping
+// from original names to the final class strings
+func transformCssModule(src []byte, filePath string) (string, map[string]string) {
+	p := cssModuleParser{
+		source:   []rune(string(src)),
+		filePath: filePath,
+		classes:  map[string]string{},
+		composed: map[string][]string{},
+	}
+	p.parse()
+
+	mapping := map[string]string{}
+	for name, hashed := range p.classes {
+		value := hashed
+		for _, extra := range p.composed[name] {
+			value += " " + extra
+		}
+		mapping[name] = value
+	}
+	return p.out.String(), mapping
+}
+
+// cssModuleExportObject prints the class name mapping as a js
+// object literal with deterministic key order
+func cssModuleExportObject(mapping map[string]string) string {
+	names := []string{}
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props := []string{}
+	for _, name := range names {
+		props = append(props, "'"+name+"': '"+mapping[name]+"'")
+	}
+	return "{" + strings.Join(props, ", ") + "}"
+}
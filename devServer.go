@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// reloadPath is the websocket endpoint the injected client runtime
+// connects to for live reload notifications
+const reloadPath = "/__go-bundler-reload"
+
+// reloadClients holds the open live reload sockets, the watcher
+// notifies them after every successful rebuild
+var reloadClients struct {
+	sync.Mutex
+	conns []net.Conn
+}
+
+// newDevServer builds the http server for the bundle directory and
+// the live reload websocket endpoint. The caller owns the server,
+// so a shutdown handler can Shutdown it cleanly
+func newDevServer(config *configJSON) *http.Server {
+	port := config.DevServer.Port
+	if port == 0 {
+		port = 8080
+	}
+
+	files := withETag(config.BundleDir, http.FileServer(http.Dir(config.BundleDir)))
+	proxies := makeProxies(config.DevServer.Proxy)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == reloadPath {
+			handleReloadSocket(w, r)
+			return
+		}
+		for prefix, proxy := range proxies {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				proxy.ServeHTTP(w, r)
+				return
+			}
+		}
+		if config.DevServer.HistoryFallback && isHistoryRoute(r.URL.Path, config.BundleDir) {
+			http.ServeFile(w, r, filepath.Join(config.BundleDir, "index.html"))
+			return
+		}
+		if servePreCompressed(w, r, config.BundleDir) {
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+
+	return &http.Server{Addr: fmt.Sprintf(":%v", port), Handler: handler}
+}
+
+// startDevServer serves until the server is shut down. The listen
+// happens separately from serving so the browser only opens once
+// the port actually accepts connections
+func startDevServer(config *configJSON, server *http.Server) {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		fmt.Println("Dev server error:", err)
+		return
+	}
+
+	url := "http://localhost" + server.Addr
+	fmt.Printf("Dev server listening on %s\n", url)
+	if config.DevServer.Open {
+		openBrowser(url)
+	}
+
+	err = server.Serve(listener)
+	if err != nil && err != http.ErrServerClosed {
+		fmt.Println("Dev server error:", err)
+	}
+}
+
+// openBrowser launches the platform's default browser at the given
+// url, a failure only costs the convenience so it just prints
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Println("Could not open the browser:", err)
+	}
+}
+
+// makeProxies builds a reverse proxy for every configured path
+// prefix, requests matching a prefix go to the backend so api
+// calls during development avoid CORS
+func makeProxies(targets map[string]string) map[string]*httputil.ReverseProxy {
+	proxies := map[string]*httputil.ReverseProxy{}
+	for prefix, target := range targets {
+		targetURL, err := url.Parse(target)
+		if err != nil {
+			fmt.Printf("Invalid proxy target for %s: %v\n", prefix, err)
+			continue
+		}
+		proxies[prefix] = httputil.NewSingleHostReverseProxy(targetURL)
+	}
+	return proxies
+}
+
+// withETag sets a strong content-hash ETag before handing the
+// request to the file server. The standard library answers
+// If-None-Match with 304 itself once the header is set, so cached
+// files validate locally the way they would behind a real server
+func withETag(bundleDir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := filepath.FromSlash(filepath.Clean(r.URL.Path))
+		if strings.HasSuffix(r.URL.Path, "/") {
+			urlPath = filepath.Join(urlPath, "index.html")
+		}
+		if content, err := os.ReadFile(filepath.Join(bundleDir, urlPath)); err == nil {
+			w.Header().Set("ETag", contentETag(content))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func contentETag(content []byte) string {
+	sum := sha1.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// servePreCompressed serves a pre-generated .gz or .br variant of
+// the requested file when the client accepts the encoding and the
+// variant exists next to the original, so local delivery matches a
+// production server configured for pre-compressed assets. Reports
+// whether it handled the request
+func servePreCompressed(w http.ResponseWriter, r *http.Request, bundleDir string) bool {
+	ext := filepath.Ext(r.URL.Path)
+	if ext == "" || ext == ".gz" || ext == ".br" {
+		return false
+	}
+	accepted := r.Header.Get("Accept-Encoding")
+	variants := []struct{ encoding, ext string }{
+		{"br", ".br"},
+		{"gzip", ".gz"},
+	}
+	for _, variant := range variants {
+		if !strings.Contains(accepted, variant.encoding) {
+			continue
+		}
+		full := filepath.Join(bundleDir, filepath.FromSlash(filepath.Clean(r.URL.Path)))
+		if stat, err := os.Stat(full + variant.ext); err != nil || stat.IsDir() {
+			continue
+		}
+		// the content type comes from the original name, the
+		// encoding from the variant being sent
+		if ctype := mime.TypeByExtension(ext); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+		// each encoding is its own representation and needs its own
+		// validator
+		if content, err := os.ReadFile(full + variant.ext); err == nil {
+			w.Header().Set("ETag", contentETag(content))
+		}
+		w.Header().Set("Content-Encoding", variant.encoding)
+		w.Header().Set("Vary", "Accept-Encoding")
+		http.ServeFile(w, r, full+variant.ext)
+		return true
+	}
+	return false
+}
+
+// isHistoryRoute tells whether a request should fall back to
+// index.html: the path matches no file in the bundle directory and
+// does not look like an asset, so a missing .js or .css still
+// returns a clear 404
+func isHistoryRoute(urlPath, bundleDir string) bool {
+	if urlPath == "/" || filepath.Ext(urlPath) != "" {
+		return false
+	}
+	full := filepath.Join(bundleDir, filepath.FromSlash(filepath.Clean(urlPath)))
+	_, err := os.Stat(full)
+	return os.IsNotExist(err)
+}
+
+// handleReloadSocket does a minimal websocket handshake and keeps
+// the connection around. We only ever push tiny text frames to the
+// client, so there is no need for a websocket library
+func handleReloadSocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "not a websocket request", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot hijack connection", http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+
+	// the accept key is fixed by the websocket spec: sha1 of the
+	// client key and a magic guid, base64 encoded
+	hash := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return
+	}
+
+	reloadClients.Lock()
+	reloadClients.conns = append(reloadClients.conns, conn)
+	reloadClients.Unlock()
+
+	// drain whatever the client sends so closed tabs are noticed
+	go func() {
+		reader := bufio.NewReader(conn)
+		buf := make([]byte, 256)
+		for {
+			if _, err := reader.Read(buf); err != nil {
+				dropReloadClient(conn)
+				return
+			}
+		}
+	}()
+}
+
+func dropReloadClient(conn net.Conn) {
+	conn.Close()
+	reloadClients.Lock()
+	defer reloadClients.Unlock()
+	for i, c := range reloadClients.conns {
+		if c == conn {
+			reloadClients.conns = append(reloadClients.conns[:i], reloadClients.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyReload tells every connected client to reload the page,
+// called by the watcher after a successful rebuild
+func notifyReload() {
+	// an unmasked text frame with a short payload: FIN+opcode,
+	// length, data
+	frame := append([]byte{0x81, byte(len("reload"))}, "reload"...)
+
+	reloadClients.Lock()
+	conns := append([]net.Conn{}, reloadClients.conns...)
+	reloadClients.Unlock()
+
+	for _, conn := range conns {
+		if _, err := conn.Write(frame); err != nil {
+			dropReloadClient(conn)
+		}
+	}
+}
+
+// reloadClientRuntime is injected into the bundle when the dev
+// server is enabled, it reloads the page when a rebuild finishes
+const reloadClientRuntime = `if (typeof WebSocket !== 'undefined') {
+new WebSocket('ws://' + location.host + '` + reloadPath + `').onmessage = function() {
+location.reload();
+};
+}
+`
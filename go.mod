@@ -0,0 +1,3 @@
+module github.com/lvl5hm/go-bundler
+
+go 1.21
@@ -0,0 +1,182 @@
+package jsLoader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node is the public view of a parsed syntax tree node, a stable
+// mirror of the parser's internal representation for tools that
+// want the parser without the bundling transform.
+//
+// Type names follow the grammar: statements end in "Statement"
+// ("IfStatement", "VarStatement"), expressions are named for their
+// shape ("Binary", "Member", "ArrowFunction") and literals for
+// their kind ("StringLiteral", "BoolLiteral"). Value carries the
+// node's own text — an identifier's name, a literal's source
+// spelling, an operator — and Children holds the sub-nodes in
+// source order. Line and Col are 1-based positions, zero on nodes
+// the parser tracks no position for
+type Node struct {
+	Type     string
+	Value    string
+	Children []Node
+	Line     int
+	Col      int
+}
+
+// Parse lexes and parses src as a standalone script and returns
+// the syntax tree. Parse errors carry line, column and a rendered
+// code frame, like the bundler's own build errors
+func Parse(src []byte) (Node, error) {
+	program, err := parse(lex(src))
+	if err != nil {
+		return Node{}, withCodeFrames(err, src)
+	}
+	return exportNode(program), nil
+}
+
+// Print renders a tree back to source text, honoring the Minify
+// flag like the bundler's own output. The tree has to be shaped
+// the way Parse returns it, a node with the wrong child count for
+// its type is not printable
+func Print(n Node) string {
+	return printAst(importNode(n))
+}
+
+// DumpAST renders a tree as an indented multi-line outline for
+// debugging, one node per line with its type, value and source
+// position where the parser tracked one
+func DumpAST(n Node) string {
+	var sb strings.Builder
+	dumpNode(&sb, n, 0)
+	return sb.String()
+}
+
+func dumpNode(sb *strings.Builder, n Node, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(n.Type)
+	if n.Value != "" {
+		fmt.Fprintf(sb, " %q", n.Value)
+	}
+	if n.Line > 0 {
+		fmt.Fprintf(sb, " (%d:%d)", n.Line, n.Col)
+	}
+	sb.WriteByte('\n')
+	for _, c := range n.Children {
+		dumpNode(sb, c, depth+1)
+	}
+}
+
+// nodeTypeNames gives every parser node type its public name, in
+// declaration order
+var nodeTypeNames = [...]string{
+	n_EMPTY:   "Empty",
+	n_PROGRAM: "Program",
+
+	n_IDENTIFIER:       "Identifier",
+	n_NUMBER_LITERAL:   "NumberLiteral",
+	n_STRING_LITERAL:   "StringLiteral",
+	n_REGEX_LITERAL:    "RegexLiteral",
+	n_BOOL_LITERAL:     "BoolLiteral",
+	n_UNDEFINED:        "Undefined",
+	n_TEMPLATE_LITERAL: "TemplateLiteral",
+	n_TEMPLATE_STRING:  "TemplateString",
+	n_TAGGED_TEMPLATE:  "TaggedTemplate",
+
+	n_ASSIGNMENT:    "Assignment",
+	n_CONDITIONAL:   "Conditional",
+	n_BINARY:        "Binary",
+	n_PREFIX_UNARY:  "PrefixUnary",
+	n_POSTFIX_UNARY: "PostfixUnary",
+	n_MEMBER:        "Member",
+	n_CALL:          "Call",
+	n_NEW:           "New",
+	n_SEQUENCE:      "Sequence",
+	n_PAREN:         "Paren",
+	n_META_PROPERTY: "MetaProperty",
+
+	n_SPREAD: "Spread",
+
+	n_OBJECT_LITERAL: "ObjectLiteral",
+	n_OBJECT_PROP:    "ObjectProp",
+	n_OBJECT_METHOD:  "ObjectMethod",
+	n_COMPUTED_KEY:   "ComputedKey",
+	n_ARRAY_LITERAL:  "ArrayLiteral",
+
+	n_FUNCTION_DECLARATION: "FunctionDeclaration",
+	n_FUNCTION_EXPRESSION:  "FunctionExpression",
+	n_ARROW_FUNCTION:       "ArrowFunction",
+	n_PARAM_LIST:           "ParamList",
+
+	n_VAR_STATEMENT:        "VarStatement",
+	n_DECLARATOR:           "Declarator",
+	n_EXPRESSION_STATEMENT: "ExpressionStatement",
+	n_BLOCK_STATEMENT:      "BlockStatement",
+	n_IF_STATEMENT:         "IfStatement",
+	n_FOR_STATEMENT:        "ForStatement",
+	n_FOR_IN_STATEMENT:     "ForInStatement",
+	n_WHILE_STATEMENT:      "WhileStatement",
+	n_DO_WHILE_STATEMENT:   "DoWhileStatement",
+	n_RETURN_STATEMENT:     "ReturnStatement",
+	n_BREAK_STATEMENT:      "BreakStatement",
+	n_CONTINUE_STATEMENT:   "ContinueStatement",
+	n_THROW_STATEMENT:      "ThrowStatement",
+	n_TRY_STATEMENT:        "TryStatement",
+	n_SWITCH_STATEMENT:     "SwitchStatement",
+	n_SWITCH_CASE:          "SwitchCase",
+	n_EMPTY_STATEMENT:      "EmptyStatement",
+	n_DEBUGGER_STATEMENT:   "DebuggerStatement",
+	n_LABELED_STATEMENT:    "LabeledStatement",
+
+	n_CLASS_DECLARATION: "ClassDeclaration",
+	n_CLASS_EXPRESSION:  "ClassExpression",
+	n_CLASS_METHOD:      "ClassMethod",
+	n_CLASS_FIELD:       "ClassField",
+	n_DECORATOR:         "Decorator",
+
+	n_IMPORT_STATEMENT:   "ImportStatement",
+	n_IMPORT_CALL:        "ImportCall",
+	n_IMPORT_DEFAULT:     "ImportDefault",
+	n_IMPORT_STAR:        "ImportStar",
+	n_IMPORT_NAMED:       "ImportNamed",
+	n_EXPORT_STATEMENT:   "ExportStatement",
+	n_EXPORT_DEFAULT:     "ExportDefault",
+	n_EXPORT_NAMED:       "ExportNamed",
+	n_EXPORT_DECLARATION: "ExportDeclaration",
+	n_EXPORT_ALL:         "ExportAll",
+}
+
+var nodeTypesByName = func() map[string]astType {
+	m := map[string]astType{}
+	for t, name := range nodeTypeNames {
+		m[name] = astType(t)
+	}
+	return m
+}()
+
+func exportNode(n ast) Node {
+	out := Node{Type: nodeTypeNames[n.t], Value: n.value}
+	if n.hasPos {
+		out.Line = n.srcLine + 1
+		out.Col = n.srcCol + 1
+	}
+	if len(n.children) > 0 {
+		out.Children = make([]Node, len(n.children))
+		for i, c := range n.children {
+			out.Children[i] = exportNode(c)
+		}
+	}
+	return out
+}
+
+func importNode(n Node) ast {
+	out := ast{t: nodeTypesByName[n.Type], value: n.Value}
+	if len(n.Children) > 0 {
+		out.children = make([]ast, len(n.Children))
+		for i, c := range n.Children {
+			out.children[i] = importNode(c)
+		}
+	}
+	return out
+}
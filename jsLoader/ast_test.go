@@ -0,0 +1,63 @@
+package jsLoader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPublicParse(t *testing.T) {
+	tree, err := Parse([]byte("var a = 1 + 2;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Type != "Program" || len(tree.Children) != 1 {
+		t.Fatalf("expected a Program with one statement, got %+v", tree)
+	}
+	statement := tree.Children[0]
+	if statement.Type != "VarStatement" || statement.Line != 1 {
+		t.Errorf("expected a VarStatement on line 1, got %+v", statement)
+	}
+	sum := statement.Children[0].Children[1]
+	if sum.Type != "Binary" || sum.Value != "+" {
+		t.Errorf("expected the initializer to be a Binary +, got %+v", sum)
+	}
+
+	_, err = Parse([]byte("var = ;"))
+	if err == nil || !strings.Contains(err.Error(), "parse error") {
+		t.Errorf("expected a parse error with position, got %v", err)
+	}
+}
+
+func TestPublicPrintRoundtrip(t *testing.T) {
+	Minify = true
+	defer func() { Minify = false }()
+
+	src := "function f(a) { return a * 2; }"
+	tree, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed := Print(tree)
+	if printed != "function f(a){return a*2}" {
+		t.Errorf("expected the tree to print back to source, got %s", printed)
+	}
+}
+
+func TestDumpAST(t *testing.T) {
+	tree, err := Parse([]byte("var a = b + 1;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := DumpAST(tree)
+	expected := `Program
+  VarStatement "var" (1:1)
+    Declarator
+      Identifier "a"
+      Binary "+"
+        Identifier "b"
+        NumberLiteral "1"
+`
+	if dump != expected {
+		t.Errorf("dump mismatch:\nexpected:\n%s\ngot:\n%s", expected, dump)
+	}
+}
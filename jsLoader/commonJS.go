@@ -0,0 +1,203 @@
+package jsLoader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// transformError is a build error at a known source position
+type transformError struct {
+	message string
+	line    int
+	col     int
+}
+
+func (e transformError) Error() string {
+	return fmt.Sprintf("error at %v:%v: %s", e.line+1, e.col+1, e.message)
+}
+
+// transformCommonJS rewrites CommonJS patterns into the same
+// variable assignments the es6 transform produces, so modules
+// using require and module.exports can live in the bundle next to
+// es6 ones. A top-level 'module.exports = X' reassignment becomes
+// the module's default export, 'exports.foo = X' becomes a named
+// export, and a require call with a literal path reads the default
+// export of the resolved file
+func transformCommonJS(program ast, moduleName, fileDir string, result *FileData) (ast, error) {
+	reassigned := false
+	for _, s := range program.children {
+		if _, ok := moduleExportsValue(s); ok {
+			reassigned = true
+		}
+	}
+
+	named := []string{}
+	for i, s := range program.children {
+		if value, ok := moduleExportsValue(s); ok {
+			result.addExport("default")
+			program.children[i] = exportAssignment(moduleName, "default", value)
+			continue
+		}
+		if name, value, ok := namedExportsValue(s); ok {
+			result.addExport(name)
+			seen := false
+			for _, n := range named {
+				if n == name {
+					seen = true
+				}
+			}
+			if !seen {
+				named = append(named, name)
+			}
+			program.children[i] = exportAssignment(moduleName, name, value)
+		}
+	}
+
+	// require consumers read the default export, so the named
+	// exports are collected into one object unless module.exports
+	// was reassigned wholesale, which wins the way it does in node
+	if len(named) > 0 && !reassigned {
+		props := []ast{}
+		for _, name := range named {
+			props = append(props, makeNode(n_OBJECT_PROP, "",
+				makeNode(n_IDENTIFIER, name),
+				makeNode(n_IDENTIFIER, moduleName+"_"+name)))
+		}
+		result.addExport("default")
+		program.children = append(program.children,
+			exportAssignment(moduleName, "default",
+				makeNode(n_OBJECT_LITERAL, "", props...)))
+	}
+
+	return transformRequireCalls(program, fileDir, result, 0, 0)
+}
+
+// injectNodeGlobals declares __dirname and __filename at the top
+// of a module that references them, code ported from node relies
+// on both being defined. Modules that never mention them stay
+// untouched so every wrapper does not grow two extra variables
+func injectNodeGlobals(program ast, filePath string) ast {
+	declarators := []ast{}
+	if referencesIdentifier(program, "__dirname") {
+		declarators = append(declarators, makeNode(n_DECLARATOR, "",
+			makeNode(n_IDENTIFIER, "__dirname"),
+			makeNode(n_STRING_LITERAL, strconv.Quote(filepath.Dir(filePath)))))
+	}
+	if referencesIdentifier(program, "__filename") {
+		declarators = append(declarators, makeNode(n_DECLARATOR, "",
+			makeNode(n_IDENTIFIER, "__filename"),
+			makeNode(n_STRING_LITERAL, strconv.Quote(filePath))))
+	}
+	if len(declarators) == 0 {
+		return program
+	}
+	decl := makeNode(n_VAR_STATEMENT, "var", declarators...)
+	program.children = append([]ast{decl}, program.children...)
+	return program
+}
+
+func referencesIdentifier(n ast, name string) bool {
+	if n.t == n_IDENTIFIER && n.value == name {
+		return true
+	}
+	for _, c := range n.children {
+		if referencesIdentifier(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// namedExportsValue matches an 'exports.<name> = X' or
+// 'module.exports.<name> = X' expression statement and returns the
+// export name and the assigned value
+func namedExportsValue(s ast) (string, ast, bool) {
+	if s.t != n_EXPRESSION_STATEMENT {
+		return "", ast{}, false
+	}
+	assignment := s.children[0]
+	if assignment.t != n_ASSIGNMENT || assignment.value != "=" {
+		return "", ast{}, false
+	}
+	member := assignment.children[0]
+	if member.t != n_MEMBER || member.value != "." ||
+		member.children[1].t != n_IDENTIFIER {
+		return "", ast{}, false
+	}
+	object := member.children[0]
+	isExports := object.t == n_IDENTIFIER && object.value == "exports"
+	if !isExports && !isModuleExports(object) {
+		return "", ast{}, false
+	}
+	return member.children[1].value, assignment.children[1], true
+}
+
+// moduleExportsValue matches a 'module.exports = X' expression
+// statement and returns the assigned value
+func moduleExportsValue(s ast) (ast, bool) {
+	if s.t != n_EXPRESSION_STATEMENT {
+		return ast{}, false
+	}
+	assignment := s.children[0]
+	if assignment.t != n_ASSIGNMENT || assignment.value != "=" {
+		return ast{}, false
+	}
+	if !isModuleExports(assignment.children[0]) {
+		return ast{}, false
+	}
+	return assignment.children[1], true
+}
+
+// isModuleExports matches the member expression 'module.exports'
+func isModuleExports(n ast) bool {
+	return n.t == n_MEMBER && n.value == "." &&
+		n.children[0].t == n_IDENTIFIER && n.children[0].value == "module" &&
+		n.children[1].t == n_IDENTIFIER && n.children[1].value == "exports"
+}
+
+// transformRequireCalls replaces require calls that have a literal
+// path with the resolved module's default export variable. A
+// non-constant path is a build error, since the target cannot be
+// known at bundle time and the call would break at runtime. The
+// line and column of the nearest enclosing statement make the
+// error findable
+func transformRequireCalls(n ast, fileDir string, result *FileData, line, col int) (ast, error) {
+	if n.hasPos {
+		line, col = n.srcLine, n.srcCol
+	}
+	for i, c := range n.children {
+		transformed, err := transformRequireCalls(c, fileDir, result, line, col)
+		if err != nil {
+			return n, err
+		}
+		n.children[i] = transformed
+	}
+
+	if n.t != n_CALL ||
+		n.children[0].t != n_IDENTIFIER || n.children[0].value != "require" {
+		return n, nil
+	}
+	if len(n.children) != 2 {
+		return n, transformError{"require takes exactly one argument", line, col}
+	}
+	requirePath := ""
+	ok := n.children[1].t == n_STRING_LITERAL
+	if ok {
+		requirePath, ok = stringLiteralValue(n.children[1])
+	}
+	if !ok {
+		return n, transformError{
+			"require needs a constant string path so the target can be bundled",
+			line, col}
+	}
+
+	if global, external := Externals[requirePath]; external {
+		return externalGlobalRef(global), nil
+	}
+
+	resolved := resolveES6ImportPath(requirePath, fileDir)
+	result.Imports = append(result.Imports, resolved)
+	result.addImportedName(resolved, "default")
+	return makeNode(n_IDENTIFIER, CreateVarNameFromPath(resolved)+"_default"), nil
+}
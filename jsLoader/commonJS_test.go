@@ -0,0 +1,138 @@
+package jsLoader
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModuleExportsReassignment(t *testing.T) {
+	src := []byte("module.exports = function() { return 1; };")
+	data, err := LoadFile(src, "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Exports) != 1 || data.Exports[0] != "default" {
+		t.Errorf("expected a default export, got %v", data.Exports)
+	}
+	code := string(data.Code)
+	if !strings.Contains(code, "_src_lib_js_default = function()") {
+		t.Errorf("expected module.exports to become the default export, got %s", code)
+	}
+	if strings.Contains(code, "module.exports") {
+		t.Errorf("expected module.exports to be rewritten, got %s", code)
+	}
+}
+
+func TestModuleExportsAssignedOnce(t *testing.T) {
+	src := []byte("module.exports = a; module.exports = b;")
+	data, err := LoadFile(src, "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Exports) != 1 || data.Exports[0] != "default" {
+		t.Errorf("expected a single default export, got %v", data.Exports)
+	}
+}
+
+func TestExportsNamedAssignments(t *testing.T) {
+	src := []byte("exports.foo = 1; module.exports.bar = 2;")
+	data, err := LoadFile(src, "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Exports) != 3 || data.Exports[0] != "foo" ||
+		data.Exports[1] != "bar" || data.Exports[2] != "default" {
+		t.Errorf("expected [foo bar default], got %v", data.Exports)
+	}
+	code := string(data.Code)
+	if !strings.Contains(code, "_src_lib_js_foo = 1") ||
+		!strings.Contains(code, "_src_lib_js_bar = 2") {
+		t.Errorf("expected named export assignments, got %s", code)
+	}
+	// require consumers get all named exports through the default
+	if !strings.Contains(code,
+		"_src_lib_js_default = {foo: _src_lib_js_foo, bar: _src_lib_js_bar}") {
+		t.Errorf("expected the default export object, got %s", code)
+	}
+}
+
+func TestExportsReassignmentWins(t *testing.T) {
+	src := []byte("exports.foo = 1; module.exports = whole;")
+	data, err := LoadFile(src, "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(data.Code)
+	if !strings.Contains(code, "_src_lib_js_default = whole") {
+		t.Errorf("expected the reassigned object as default, got %s", code)
+	}
+	if strings.Contains(code, "_src_lib_js_default = {") {
+		t.Errorf("reassignment must not be overwritten by the named export object, got %s", code)
+	}
+}
+
+func TestRequireLiteralPath(t *testing.T) {
+	src := []byte("var lib = require('./lib.js'); lib();")
+	data, err := LoadFile(src, "src/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved := filepath.Join("src", "lib.js")
+	if len(data.Imports) != 1 || data.Imports[0] != resolved {
+		t.Errorf("expected the required file as an import, got %v", data.Imports)
+	}
+	names := data.ImportedNames[resolved]
+	if len(names) != 1 || names[0] != "default" {
+		t.Errorf("expected require to use the default export, got %v", names)
+	}
+	if !strings.Contains(string(data.Code), "var lib = _src_lib_js_default") {
+		t.Errorf("expected require to read the default export, got %s", data.Code)
+	}
+}
+
+func TestRequireNonLiteralIsError(t *testing.T) {
+	src := []byte("setup();\nvar lib = require('./' + name);")
+	_, err := LoadFile(src, "src/index.js")
+	if err == nil {
+		t.Fatal("expected an error for a non-constant require path")
+	}
+	// the position points at the statement holding the call
+	if !strings.Contains(err.Error(), "at 2:1") {
+		t.Errorf("expected the error to carry the source position, got %v", err)
+	}
+}
+
+func TestNodeGlobalsInjected(t *testing.T) {
+	src := []byte("log(__dirname);")
+	data, err := LoadFile(src, filepath.Join("src", "lib.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(data.Code)
+	if !strings.Contains(code, `var __dirname = "src"`) {
+		t.Errorf("expected __dirname to be declared, got %s", code)
+	}
+	if strings.Contains(code, "__filename =") {
+		t.Errorf("unreferenced __filename should not be declared, got %s", code)
+	}
+}
+
+func TestNodeGlobalsOnlyWhenUsed(t *testing.T) {
+	src := []byte("log('hi');")
+	data, err := LoadFile(src, "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data.Code), "__dirname") {
+		t.Errorf("expected no injection without references, got %s", data.Code)
+	}
+}
+
+func TestRequireWrongArity(t *testing.T) {
+	src := []byte("require();")
+	_, err := LoadFile(src, "src/index.js")
+	if err == nil {
+		t.Fatal("expected an error for require without arguments")
+	}
+}
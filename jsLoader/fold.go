@@ -0,0 +1,149 @@
+package jsLoader
+
+import (
+	"strings"
+)
+
+// foldConstants evaluates expressions whose value is known at
+// bundle time and removes the branches they make unreachable, e.g.
+// after process.env substitution turned a condition into a
+// comparison of two string literals. Libraries guard dev-only code
+// this way, so folding shrinks production output. The pass only
+// touches expressions staticTruth can prove, which are all free of
+// side effects, so nothing that could run at runtime is dropped
+func foldConstants(n ast) ast {
+	for i, c := range n.children {
+		n.children[i] = foldConstants(c)
+	}
+
+	switch n.t {
+	case n_BINARY:
+		switch n.value {
+		case "===", "==", "!==", "!=":
+			left, leftOk := stringLiteralValue(n.children[0])
+			right, rightOk := stringLiteralValue(n.children[1])
+			if leftOk && rightOk {
+				equal := left == right
+				if n.value == "!==" || n.value == "!=" {
+					equal = !equal
+				}
+				return boolNode(equal)
+			}
+
+		case "+":
+			// adjacent string literals concatenate at compile time.
+			// The left-associative parse folds leading runs like
+			// "a" + "b" + x pair by pair on the way up
+			left, leftOk := stringLiteralValue(n.children[0])
+			right, rightOk := stringLiteralValue(n.children[1])
+			if leftOk && rightOk {
+				quote := n.children[0].value[:1]
+				if !strings.Contains(left+right, quote) {
+					return makeNode(n_STRING_LITERAL, quote+left+right+quote)
+				}
+			}
+
+		case "&&":
+			if truth, known := staticTruth(n.children[0]); known {
+				if truth {
+					return n.children[1]
+				}
+				// the right side never evaluates, the expression's
+				// value is the falsy left operand itself
+				return n.children[0]
+			}
+
+		case "||":
+			if truth, known := staticTruth(n.children[0]); known {
+				if truth {
+					return n.children[0]
+				}
+				return n.children[1]
+			}
+		}
+
+	case n_CONDITIONAL:
+		if truth, known := staticTruth(n.children[0]); known {
+			if truth {
+				return n.children[1]
+			}
+			return n.children[2]
+		}
+
+	case n_IF_STATEMENT:
+		truth, known := staticTruth(n.children[0])
+		if !known {
+			return n
+		}
+		if truth {
+			return n.children[1]
+		}
+		if n.children[2].t != n_EMPTY {
+			return n.children[2]
+		}
+		return makeNode(n_EMPTY_STATEMENT, "")
+	}
+	return n
+}
+
+func boolNode(value bool) ast {
+	if value {
+		return makeNode(n_BOOL_LITERAL, "true")
+	}
+	return makeNode(n_BOOL_LITERAL, "false")
+}
+
+// staticTruth evaluates a condition if its value is known at
+// bundle time, the second result tells whether it was
+func staticTruth(n ast) (bool, bool) {
+	switch n.t {
+	case n_PAREN:
+		return staticTruth(n.children[0])
+
+	case n_BOOL_LITERAL:
+		return n.value == "true", true
+
+	case n_UNDEFINED:
+		return false, true
+
+	case n_STRING_LITERAL:
+		if value, ok := stringLiteralValue(n); ok {
+			return value != "", true
+		}
+
+	case n_NUMBER_LITERAL:
+		return n.value != "0", true
+
+	case n_PREFIX_UNARY:
+		if n.value == "!" {
+			if truth, known := staticTruth(n.children[0]); known {
+				return !truth, true
+			}
+		}
+
+	case n_BINARY:
+		left, leftOk := stringLiteralValue(n.children[0])
+		right, rightOk := stringLiteralValue(n.children[1])
+		if leftOk && rightOk {
+			switch n.value {
+			case "===", "==":
+				return left == right, true
+			case "!==", "!=":
+				return left != right, true
+			}
+		}
+	}
+	return false, false
+}
+
+// stringLiteralValue unwraps a string literal's quotes, escaped
+// strings are left alone since comparing their raw text would lie
+func stringLiteralValue(n ast) (string, bool) {
+	if n.t != n_STRING_LITERAL || len(n.value) < 2 {
+		return "", false
+	}
+	if strings.ContainsRune(n.value, '\\') {
+		return "", false
+	}
+	return n.value[1 : len(n.value)-1], true
+}
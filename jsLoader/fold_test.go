@@ -0,0 +1,88 @@
+package jsLoader
+
+import "testing"
+
+func expectFolded(t *testing.T, src, expected string) {
+	t.Helper()
+	Minify = true
+	defer func() { Minify = false }()
+
+	program, err := parse(lex([]byte(src)))
+	if err != nil {
+		t.Fatalf("parse error in %q: %v", src, err)
+	}
+	printed := printAst(foldConstants(program))
+	if printed != expected {
+		t.Errorf("fold mismatch:\nsource:   %s\nexpected: %s\ngot:      %s",
+			src, expected, printed)
+	}
+}
+
+func TestFoldConstantComparisons(t *testing.T) {
+	expectFolded(t,
+		`var a = "production" === "production";`,
+		"var a=!0")
+	expectFolded(t,
+		`var b = "a" !== "b";`,
+		"var b=!0")
+	// only literal operands fold, runtime values stay
+	expectFolded(t,
+		"var c = env === 'production';",
+		"var c=env==='production'")
+}
+
+func TestFoldStringConcatenation(t *testing.T) {
+	expectFolded(t,
+		`var a = "foo" + "bar";`,
+		`var a="foobar"`)
+	// a leading constant run folds, the variable tail stays
+	expectFolded(t,
+		`var b = "a" + "b" + x;`,
+		`var b="ab"+x`)
+	// the left association keeps trailing literals apart from a
+	// variable head
+	expectFolded(t,
+		`var c = x + "a" + "b";`,
+		`var c=x+"a"+"b"`)
+	expectFolded(t,
+		`var d = "don't " + 'stop';`,
+		`var d="don't stop"`)
+	// number addition is left alone
+	expectFolded(t,
+		`var e = "n" + 1;`,
+		`var e="n"+1`)
+}
+
+func TestFoldLogicalOperators(t *testing.T) {
+	expectFolded(t,
+		"var a = true && f();",
+		"var a=f()")
+	expectFolded(t,
+		"var b = false && f();",
+		"var b=!1")
+	expectFolded(t,
+		"var c = false || f();",
+		"var c=f()")
+	expectFolded(t,
+		"var d = true || f();",
+		"var d=!0")
+	// a left side with possible side effects is never dropped
+	expectFolded(t,
+		"var e = f() && g();",
+		"var e=f()&&g()")
+}
+
+func TestFoldConditionalArms(t *testing.T) {
+	expectFolded(t,
+		"var a = 'x' ? yes() : no();",
+		"var a=yes()")
+	expectFolded(t,
+		"var b = 0 ? yes() : no();",
+		"var b=no()")
+	expectFolded(t,
+		"if ('dev' === 'prod') { a(); } else { b(); }",
+		"{b()}")
+	expectFolded(t,
+		"if (cond) { a(); }",
+		"if(cond){a()}")
+}
@@ -0,0 +1,582 @@
+package jsLoader
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileData is the result of loading a single module: the
+// transformed code and everything the bundler needs to know
+// about its place in the dependency graph
+type FileData struct {
+	Code             []byte
+	Imports          []string
+	Exports          []string
+	StarReexports    []string
+	NamespaceImports []string
+	Mappings         []Mapping
+	// ImportedNames maps an imported file to the export names this
+	// module references, used by the bundler for tree-shaking
+	ImportedNames map[string][]string
+	// DynamicImports lists files loaded with import(), they go
+	// into separate chunks instead of the main bundle
+	DynamicImports []string
+	// CSS holds the stylesheet text of a css module when css
+	// extraction is on, the bundler collects it into a css bundle
+	CSS []byte
+}
+
+// CreateVarNameFromPath makes a valid js identifier out of a file
+// path, used as a prefix for all variables a module exports
+func CreateVarNameFromPath(path string) string {
+	var sb strings.Builder
+	for _, c := range path {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9') {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return "_" + sb.String()
+}
+
+// ResolveOptions controls how import specifiers are turned into
+// file paths
+type ResolveOptions struct {
+	// PreserveSymlinks keeps the symlink path as the module key
+	// instead of resolving to the real location. By default
+	// symlinks are resolved so a package symlinked into
+	// node_modules (monorepo workspaces) gets a single canonical
+	// module key no matter how it is reached
+	PreserveSymlinks bool
+	// Extensions are tried in order when an import has no
+	// extension or does not name an existing file. Defaults to
+	// .js, .jsx, then .ts
+	Extensions []string
+	// Alias maps specifier prefixes to directories (or files), so
+	// deep relative imports can be written as @components/Button
+	Alias map[string]string
+}
+
+var defaultExtensions = []string{".js", ".jsx", ".ts"}
+
+func resolveExtensions() []string {
+	if len(Resolve.Extensions) > 0 {
+		return Resolve.Extensions
+	}
+	return defaultExtensions
+}
+
+// resolveFileExtension finds the file an extensionless (or
+// unresolvable) path refers to by trying the configured
+// extensions in order
+func resolveFileExtension(path string) string {
+	if pathExists(path) && !isDir(path) {
+		return path
+	}
+	for _, ext := range resolveExtensions() {
+		if pathExists(path + ext) {
+			return path + ext
+		}
+	}
+	if filepath.Ext(path) == "" {
+		return path + resolveExtensions()[0]
+	}
+	return path
+}
+
+// Resolve holds the resolver options for the current build
+var Resolve ResolveOptions
+
+// Define maps environment names to values, references like
+// process.env.NODE_ENV are replaced with the value inline so dead
+// branches can be eliminated later
+var Define map[string]string
+
+// Externals maps module names to global variable names. Imports of
+// an external module resolve to the global (for libraries loaded
+// from a CDN) instead of being bundled
+var Externals map[string]string
+
+// externalGlobalRef builds a window.<global> reference, going
+// through window keeps the name safe from mangling and shadowing
+func externalGlobalRef(global string) ast {
+	return makeNode(n_MEMBER, ".",
+		makeNode(n_IDENTIFIER, "window"),
+		makeNode(n_IDENTIFIER, global))
+}
+
+// externalImportVars binds an import statement's specifiers to the
+// configured global of an external module, e.g.
+// import React, { useState } from 'react' becomes
+// var React = window.React, useState = window.React.useState;
+func externalImportVars(s ast, global string) []ast {
+	declarators := []ast{}
+	for _, spec := range s.children {
+		switch spec.t {
+		case n_IMPORT_DEFAULT, n_IMPORT_STAR:
+			declarators = append(declarators, makeNode(n_DECLARATOR, "",
+				spec.children[0], externalGlobalRef(global)))
+		case n_IMPORT_NAMED:
+			declarators = append(declarators, makeNode(n_DECLARATOR, "",
+				spec.children[1],
+				makeNode(n_MEMBER, ".",
+					externalGlobalRef(global),
+					makeNode(n_IDENTIFIER, spec.children[0].value))))
+		}
+	}
+	if len(declarators) == 0 {
+		return nil
+	}
+	return []ast{makeNode(n_VAR_STATEMENT, "var", declarators...)}
+}
+
+// substituteDefines replaces process.env.X member expressions with
+// their configured string values
+func substituteDefines(n ast) ast {
+	if n.t == n_MEMBER && n.value == "." &&
+		n.children[1].t == n_IDENTIFIER {
+		object := n.children[0]
+		if object.t == n_MEMBER && object.value == "." &&
+			object.children[0].t == n_IDENTIFIER && object.children[0].value == "process" &&
+			object.children[1].t == n_IDENTIFIER && object.children[1].value == "env" {
+			if value, ok := Define[n.children[1].value]; ok {
+				return makeNode(n_STRING_LITERAL, strconv.Quote(value))
+			}
+		}
+	}
+	for i, c := range n.children {
+		n.children[i] = substituteDefines(c)
+	}
+	return n
+}
+
+// resolvePackageMain resolves a package directory to its entry
+// file using the "main" field of package.json, falling back to
+// index.js
+func resolvePackageMain(pkgDir string) string {
+	file, err := os.ReadFile(filepath.Join(pkgDir, "package.json"))
+	if err == nil {
+		var pkg struct {
+			Main string `json:"main"`
+		}
+		if json.Unmarshal(file, &pkg) == nil && pkg.Main != "" {
+			result := filepath.Join(pkgDir, pkg.Main)
+			if filepath.Ext(result) == "" {
+				result += ".js"
+			}
+			return result
+		}
+	}
+	return filepath.Join(pkgDir, "index.js")
+}
+
+func isDir(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// findNodeModules walks up from the importing file's directory
+// looking for a node_modules that actually contains the package,
+// the way node does
+func findNodeModules(specifier, currentFileDir string) string {
+	name, _ := splitPackageSpecifier(specifier)
+
+	dir := currentFileDir
+	for {
+		nodeModules := filepath.Join(dir, "node_modules")
+		pkgDir := filepath.Join(nodeModules, name)
+		if pathExists(pkgDir) || pathExists(pkgDir+".js") {
+			return nodeModules
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// nothing found anywhere, fall back to the local one
+			return filepath.Join(currentFileDir, "node_modules")
+		}
+		dir = parent
+	}
+}
+
+// splitPackageSpecifier splits a bare import specifier into the
+// package name and the subpath inside the package. Scoped
+// packages keep their '@scope/' prefix as part of the name
+func splitPackageSpecifier(specifier string) (string, string) {
+	parts := strings.Split(specifier, "/")
+	nameParts := 1
+	if strings.HasPrefix(specifier, "@") {
+		nameParts = 2
+	}
+	if len(parts) <= nameParts {
+		return specifier, ""
+	}
+	return strings.Join(parts[:nameParts], "/"),
+		strings.Join(parts[nameParts:], "/")
+}
+
+// resolveNodeModule resolves a bare import specifier inside a
+// node_modules directory, handling scoped names and subpaths
+func resolveNodeModule(specifier, nodeModulesDir string) string {
+	name, subpath := splitPackageSpecifier(specifier)
+	pkgDir := filepath.Join(nodeModulesDir, name)
+
+	if subpath == "" {
+		if isDir(pkgDir) {
+			return resolvePackageMain(pkgDir)
+		}
+		return resolveFileExtension(pkgDir)
+	}
+
+	result := filepath.Join(pkgDir, subpath)
+	if isDir(result) {
+		return resolvePackageMain(result)
+	}
+	return resolveFileExtension(result)
+}
+
+// resolveES6ImportPath turns an import specifier into a path
+// relative to the working directory. Specifiers are normalized to
+// forward slashes first: sources written on Windows sometimes use
+// backslashes, and the filesystem work below goes through filepath
+// so the resolved path always uses the native separator
+func resolveES6ImportPath(importPath, currentFileDir string) string {
+	importPath = strings.ReplaceAll(importPath, "\\", "/")
+	currentFileDir = filepath.FromSlash(currentFileDir)
+
+	// a specifier may carry a ?query or #fragment which is not part
+	// of the file name. The query is kept on the resolved path so
+	// loaders can act on it, the fragment is dropped
+	importPath, query := SplitQuery(importPath)
+	importPath = applyAlias(importPath)
+
+	var result string
+	if abs := filepath.FromSlash(importPath); filepath.IsAbs(abs) {
+		// alias targets are usually absolute project directories
+		result = abs
+		if isDir(result) {
+			result = resolvePackageMain(result)
+		} else {
+			result = resolveFileExtension(result)
+		}
+	} else if strings.HasPrefix(importPath, ".") {
+		result = filepath.Join(currentFileDir, importPath)
+		if isDir(result) {
+			result = resolvePackageMain(result)
+		} else {
+			result = resolveFileExtension(result)
+		}
+	} else {
+		result = resolveNodeModule(importPath,
+			findNodeModules(importPath, currentFileDir))
+	}
+	if !Resolve.PreserveSymlinks {
+		if real, err := filepath.EvalSymlinks(result); err == nil {
+			result = real
+		}
+	}
+	if query != "" {
+		result += "?" + query
+	}
+	return result
+}
+
+// applyAlias substitutes a configured alias prefix in the import
+// specifier. The longest matching alias wins, and a prefix only
+// matches whole path segments so '@comp' never claims
+// '@components/Button'
+func applyAlias(importPath string) string {
+	best := ""
+	for alias := range Resolve.Alias {
+		if len(alias) <= len(best) {
+			continue
+		}
+		if importPath == alias || strings.HasPrefix(importPath, alias+"/") {
+			best = alias
+		}
+	}
+	if best == "" {
+		return importPath
+	}
+	return Resolve.Alias[best] + importPath[len(best):]
+}
+
+// SplitQuery splits the ?query an import specifier or resolved
+// module path may carry off the file name, dropping any #fragment
+func SplitQuery(path string) (string, string) {
+	i := strings.IndexAny(path, "?#")
+	if i < 0 {
+		return path, ""
+	}
+	query := ""
+	if path[i] == '?' {
+		query = path[i+1:]
+		if j := strings.Index(query, "#"); j >= 0 {
+			query = query[:j]
+		}
+	}
+	return path[:i], query
+}
+
+// LoadFile parses a js file and rewrites es6 imports and exports
+// into plain variable assignments prefixed with the module name
+func LoadFile(src []byte, filePath string) (FileData, error) {
+	return LoadFileWithoutExports(src, filePath, nil)
+}
+
+// parseModule picks the parser for the file: .ts files go through
+// the type-stripping typescript parser
+func parseModule(tokens []token, filePath string) (ast, error) {
+	path, _ := SplitQuery(filePath)
+	if filepath.Ext(path) == ".ts" {
+		return parseTypeScript(tokens)
+	}
+	return parse(tokens)
+}
+
+// LoadFileWithoutExports is LoadFile with a set of named exports
+// the bundler determined are never imported; their assignments are
+// omitted from the output and they are not reported as exports.
+// Declarations stay since the module may use them internally
+func LoadFileWithoutExports(src []byte, filePath string, dropped map[string]bool) (FileData, error) {
+	result := FileData{}
+
+	tokens, banners := lexWithComments(src)
+	program, err := parseModule(tokens, filePath)
+	if err != nil {
+		return result, withCodeFrames(err, src)
+	}
+
+	moduleName := CreateVarNameFromPath(filePath)
+	fileDir := filepath.Dir(filePath)
+
+	statements := []ast{}
+	for _, s := range program.children {
+		switch s.t {
+		case n_IMPORT_STATEMENT:
+			if global, external := Externals[s.value]; external {
+				statements = append(statements, externalImportVars(s, global)...)
+				continue
+			}
+			resolved := resolveES6ImportPath(s.value, fileDir)
+			result.Imports = append(result.Imports, resolved)
+			importedName := CreateVarNameFromPath(resolved)
+
+			declarators := []ast{}
+			for _, spec := range s.children {
+				switch spec.t {
+				case n_IMPORT_DEFAULT:
+					result.addImportedName(resolved, "default")
+					declarators = append(declarators, makeNode(n_DECLARATOR, "",
+						spec.children[0],
+						makeNode(n_IDENTIFIER, importedName+"_default")))
+				case n_IMPORT_NAMED:
+					result.addImportedName(resolved, spec.children[0].value)
+					declarators = append(declarators, makeNode(n_DECLARATOR, "",
+						spec.children[1],
+						makeNode(n_IDENTIFIER, importedName+"_"+spec.children[0].value)))
+				case n_IMPORT_STAR:
+					result.NamespaceImports = append(result.NamespaceImports, resolved)
+					declarators = append(declarators, makeNode(n_DECLARATOR, "",
+						spec.children[0],
+						makeNode(n_IDENTIFIER, importedName+"_namespace")))
+				}
+			}
+			if len(declarators) > 0 {
+				statements = append(statements,
+					makeNode(n_VAR_STATEMENT, "var", declarators...))
+			}
+
+		case n_EXPORT_STATEMENT:
+			statements = append(statements,
+				transformExport(s, moduleName, fileDir, &result, dropped)...)
+
+		default:
+			statements = append(statements, s)
+		}
+	}
+
+	program.children = statements
+	program, err = transformCommonJS(program, moduleName, fileDir, &result)
+	if err != nil {
+		return result, err
+	}
+	program = injectNodeGlobals(program, filePath)
+	program = substituteDynamicImports(program, fileDir, &result)
+	if len(Define) > 0 {
+		program = substituteDefines(program)
+	}
+	program = foldConstants(program)
+	if DropDebugger || len(DropConsole) > 0 {
+		program = stripDevStatements(program)
+	}
+	if Mangle {
+		program = mangleProgram(program)
+	}
+	code, mappings := printAstWithMappings(program)
+
+	// preserved comments go to the top of the module, the mappings
+	// shift down by the lines they occupy
+	if len(banners) > 0 {
+		prefix := strings.Join(banners, "\n") + "\n"
+		for i := range mappings {
+			mappings[i].GenLine += strings.Count(prefix, "\n")
+		}
+		code = prefix + code
+	}
+
+	result.Code = []byte(code)
+	result.Mappings = mappings
+	return result, nil
+}
+
+// ChunkFileName is the file a dynamically imported module's chunk
+// is written to. It only hashes the path, not the content, so the
+// transform can refer to the chunk before it is generated
+func ChunkFileName(path string) string {
+	hash := sha1.Sum([]byte(path))
+	return "chunk." + hex.EncodeToString(hash[:])[:8] + ".js"
+}
+
+// substituteDynamicImports replaces import() calls that have a
+// literal path with a runtime call that loads the module's chunk
+// and resolves to its namespace object. Non-literal arguments are
+// left alone since the target cannot be known at bundle time
+func substituteDynamicImports(n ast, fileDir string, result *FileData) ast {
+	for i, c := range n.children {
+		n.children[i] = substituteDynamicImports(c, fileDir, result)
+	}
+
+	if n.t != n_IMPORT_CALL || n.children[0].t != n_STRING_LITERAL {
+		return n
+	}
+	importPath, ok := stringLiteralValue(n.children[0])
+	if !ok {
+		return n
+	}
+
+	resolved := resolveES6ImportPath(importPath, fileDir)
+	result.DynamicImports = append(result.DynamicImports, resolved)
+
+	namespace := CreateVarNameFromPath(resolved) + "_namespace"
+	getModule := makeNode(n_FUNCTION_EXPRESSION, "",
+		emptyNode,
+		makeNode(n_PARAM_LIST, ""),
+		makeNode(n_BLOCK_STATEMENT, "",
+			makeNode(n_RETURN_STATEMENT, "",
+				makeNode(n_IDENTIFIER, namespace))))
+
+	return makeNode(n_CALL, "",
+		makeNode(n_IDENTIFIER, "__goBundlerImport"),
+		makeNode(n_STRING_LITERAL, "'"+ChunkFileName(resolved)+"'"),
+		getModule)
+}
+
+// addExport records an export name once, repeated module.exports
+// assignments must not duplicate it
+func (f *FileData) addExport(name string) {
+	for _, e := range f.Exports {
+		if e == name {
+			return
+		}
+	}
+	f.Exports = append(f.Exports, name)
+}
+
+func (f *FileData) addImportedName(path, name string) {
+	if f.ImportedNames == nil {
+		f.ImportedNames = map[string][]string{}
+	}
+	f.ImportedNames[path] = append(f.ImportedNames[path], name)
+}
+
+// exportAssignment makes a '<module>_<name> = value;' statement
+func exportAssignment(moduleName, exportName string, value ast) ast {
+	return makeNode(n_EXPRESSION_STATEMENT, "",
+		makeNode(n_ASSIGNMENT, "=",
+			makeNode(n_IDENTIFIER, moduleName+"_"+exportName),
+			value))
+}
+
+func transformExport(s ast, moduleName, fileDir string, result *FileData, dropped map[string]bool) []ast {
+	statements := []ast{}
+
+	fromPath := ""
+	fromName := ""
+	if s.value != "" {
+		fromPath = resolveES6ImportPath(s.value, fileDir)
+		result.Imports = append(result.Imports, fromPath)
+		fromName = CreateVarNameFromPath(fromPath)
+	}
+
+	for _, spec := range s.children {
+		switch spec.t {
+		case n_EXPORT_DEFAULT:
+			result.Exports = append(result.Exports, "default")
+			value := spec.children[0]
+			if value.t == n_FUNCTION_DECLARATION || value.t == n_CLASS_DECLARATION {
+				statements = append(statements, value)
+				value = value.children[0]
+			}
+			statements = append(statements,
+				exportAssignment(moduleName, "default", value))
+
+		case n_EXPORT_NAMED:
+			exported := spec.children[0].value
+			local := spec.children[1].value
+			if fromName != "" {
+				result.addImportedName(fromPath, local)
+			}
+			if dropped[exported] {
+				continue
+			}
+			result.Exports = append(result.Exports, exported)
+			var value ast
+			if fromName != "" {
+				value = makeNode(n_IDENTIFIER, fromName+"_"+local)
+			} else {
+				value = makeNode(n_IDENTIFIER, local)
+			}
+			statements = append(statements,
+				exportAssignment(moduleName, exported, value))
+
+		case n_EXPORT_DECLARATION:
+			decl := spec.children[0]
+			statements = append(statements, decl)
+			if decl.t == n_FUNCTION_DECLARATION || decl.t == n_CLASS_DECLARATION {
+				name := decl.children[0].value
+				if dropped[name] {
+					continue
+				}
+				result.Exports = append(result.Exports, name)
+				statements = append(statements,
+					exportAssignment(moduleName, name, decl.children[0]))
+			} else {
+				for _, declarator := range decl.children {
+					name := declarator.children[0].value
+					if dropped[name] {
+						continue
+					}
+					result.Exports = append(result.Exports, name)
+					statements = append(statements,
+						exportAssignment(moduleName, name, declarator.children[0]))
+				}
+			}
+
+		case n_EXPORT_ALL:
+			result.StarReexports = append(result.StarReexports, fromPath)
+		}
+	}
+
+	return statements
+}
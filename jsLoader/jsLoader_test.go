@@ -0,0 +1,327 @@
+package jsLoader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePackageMain(t *testing.T) {
+	tmp := t.TempDir()
+
+	pkgDir := filepath.Join(tmp, "node_modules", "lib")
+	if err := os.MkdirAll(pkgDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(pkgDir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("package.json", `{"main": "dist/lib.js"}`)
+
+	resolved := resolveES6ImportPath("lib", tmp)
+	if resolved != filepath.Join(pkgDir, "dist", "lib.js") {
+		t.Errorf("expected main field resolution, got %s", resolved)
+	}
+
+	// without a main field the package entry falls back to index.js
+	writeFile("package.json", `{"name": "lib"}`)
+	resolved = resolveES6ImportPath("lib", tmp)
+	if resolved != filepath.Join(pkgDir, "index.js") {
+		t.Errorf("expected index.js fallback, got %s", resolved)
+	}
+}
+
+func TestResolveScopedPackage(t *testing.T) {
+	tmp := t.TempDir()
+
+	pkgDir := filepath.Join(tmp, "node_modules", "@scope", "pkg")
+	if err := os.MkdirAll(filepath.Join(pkgDir, "lib"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "package.json"),
+		[]byte(`{"main": "lib/entry.js"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := resolveES6ImportPath("@scope/pkg", tmp)
+	if resolved != filepath.Join(pkgDir, "lib", "entry.js") {
+		t.Errorf("expected scoped package main resolution, got %s", resolved)
+	}
+
+	// subpaths inside a scoped package bypass the main field
+	resolved = resolveES6ImportPath("@scope/pkg/lib/other", tmp)
+	if resolved != filepath.Join(pkgDir, "lib", "other.js") {
+		t.Errorf("expected scoped subpath resolution, got %s", resolved)
+	}
+}
+
+func TestResolveWalksUpForNodeModules(t *testing.T) {
+	tmp := t.TempDir()
+
+	pkgDir := filepath.Join(tmp, "node_modules", "lib")
+	if err := os.MkdirAll(pkgDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "index.js"), []byte(""), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	deepDir := filepath.Join(tmp, "src", "components", "deep")
+	if err := os.MkdirAll(deepDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := resolveES6ImportPath("lib", deepDir)
+	if resolved != filepath.Join(pkgDir, "index.js") {
+		t.Errorf("expected resolution from ancestor node_modules, got %s", resolved)
+	}
+}
+
+func TestResolveConfigurableExtensions(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "Button.jsx"), []byte(""), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { Resolve = ResolveOptions{} }()
+	Resolve = ResolveOptions{Extensions: []string{".js", ".jsx"}}
+
+	resolved := resolveES6ImportPath("./Button", tmp)
+	if resolved != filepath.Join(tmp, "Button.jsx") {
+		t.Errorf("expected .jsx resolution, got %s", resolved)
+	}
+
+	// .jsx is part of the default extension list, so the jsx file
+	// resolves without any configuration too
+	Resolve = ResolveOptions{}
+	resolved = resolveES6ImportPath("./Button", tmp)
+	if resolved != filepath.Join(tmp, "Button.jsx") {
+		t.Errorf("expected default .jsx resolution, got %s", resolved)
+	}
+}
+
+func TestResolveDirectoryImport(t *testing.T) {
+	tmp := t.TempDir()
+
+	dir := filepath.Join(tmp, "components")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	// a plain directory resolves to its index.js
+	resolved := resolveES6ImportPath("./components", tmp)
+	if resolved != filepath.Join(dir, "index.js") {
+		t.Errorf("expected directory index resolution, got %s", resolved)
+	}
+
+	// a package.json main inside the directory takes precedence
+	if err := os.WriteFile(filepath.Join(dir, "package.json"),
+		[]byte(`{"main": "entry.js"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	resolved = resolveES6ImportPath("./components", tmp)
+	if resolved != filepath.Join(dir, "entry.js") {
+		t.Errorf("expected directory main resolution, got %s", resolved)
+	}
+}
+
+func TestResolveSymlinkedPackage(t *testing.T) {
+	tmp := t.TempDir()
+
+	pkgDir := filepath.Join(tmp, "pkg-src")
+	if err := os.MkdirAll(pkgDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "lib.js"), []byte("export var x = 1;"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	appModules := filepath.Join(tmp, "app", "node_modules")
+	if err := os.MkdirAll(appModules, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(pkgDir, filepath.Join(appModules, "pkg")); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+
+	defer func() { Resolve = ResolveOptions{} }()
+
+	Resolve = ResolveOptions{PreserveSymlinks: false}
+	viaSymlink := resolveES6ImportPath("pkg/lib", filepath.Join(tmp, "app"))
+	direct := resolveES6ImportPath("./pkg-src/lib", tmp)
+	if viaSymlink != direct {
+		t.Errorf("expected symlinked import to resolve to the real path,\ngot  %s\nwant %s",
+			viaSymlink, direct)
+	}
+	if CreateVarNameFromPath(viaSymlink) != CreateVarNameFromPath(direct) {
+		t.Errorf("module keys differ for the same package: %s vs %s",
+			CreateVarNameFromPath(viaSymlink), CreateVarNameFromPath(direct))
+	}
+
+	Resolve = ResolveOptions{PreserveSymlinks: true}
+	preserved := resolveES6ImportPath("pkg/lib", filepath.Join(tmp, "app"))
+	if preserved != filepath.Join(appModules, "pkg", "lib.js") {
+		t.Errorf("expected symlink path to be preserved, got %s", preserved)
+	}
+}
+
+func TestResolveBackslashSpecifier(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "lib")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "util.js"), []byte(""), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// sources written on windows may use backslashes in specifiers
+	resolved := resolveES6ImportPath(`.\lib\util.js`, tmp)
+	forward := resolveES6ImportPath("./lib/util.js", tmp)
+	if resolved != forward {
+		t.Errorf("expected backslash specifier to resolve like the forward one,\ngot  %s\nwant %s",
+			resolved, forward)
+	}
+}
+
+func TestResolveStripsQueryAndFragment(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "logo.png"), []byte("png"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// the query survives on the resolved path so loaders see it
+	resolved := resolveES6ImportPath("./logo.png?inline", tmp)
+	if resolved != filepath.Join(tmp, "logo.png")+"?inline" {
+		t.Errorf("expected the query kept after the real file name, got %s", resolved)
+	}
+
+	// fragments are cache busters for browsers, not for us
+	resolved = resolveES6ImportPath("./logo.png#frag", tmp)
+	if resolved != filepath.Join(tmp, "logo.png") {
+		t.Errorf("expected the fragment to be dropped, got %s", resolved)
+	}
+
+	clean, query := SplitQuery(resolved + "?inline#frag")
+	if clean != filepath.Join(tmp, "logo.png") || query != "inline" {
+		t.Errorf("expected SplitQuery to separate the parts, got %s %s", clean, query)
+	}
+}
+
+func TestLoadFileTracksImportedNames(t *testing.T) {
+	src := []byte("import def, { named } from './dep.js'; use(def, named);")
+	data, err := LoadFile(src, "src/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := data.ImportedNames[filepath.Join("src", "dep.js")]
+	if len(names) != 2 || names[0] != "default" || names[1] != "named" {
+		t.Errorf("expected [default named], got %v", names)
+	}
+}
+
+func TestLoadFileWithoutExports(t *testing.T) {
+	src := []byte("export var used = 1; export var dead = 2;")
+	data, err := LoadFileWithoutExports(src, "src/lib.js",
+		map[string]bool{"dead": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Exports) != 1 || data.Exports[0] != "used" {
+		t.Errorf("expected only the used export, got %v", data.Exports)
+	}
+	code := string(data.Code)
+	if !strings.Contains(code, "var dead = 2") {
+		t.Errorf("the declaration itself should stay, got %s", code)
+	}
+	if strings.Contains(code, "_src_lib_js_dead") {
+		t.Errorf("dead export assignment should be dropped, got %s", code)
+	}
+	if !strings.Contains(code, "_src_lib_js_used = used") {
+		t.Errorf("used export assignment should stay, got %s", code)
+	}
+}
+
+func TestExternalModules(t *testing.T) {
+	Externals = map[string]string{"react": "React"}
+	defer func() { Externals = nil }()
+
+	data, err := LoadFile(
+		[]byte("import React, { useState } from 'react';\nuse(React, useState);"),
+		filepath.Join("src", "a.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(data.Code)
+	if !strings.Contains(code, "var React = window.React, useState = window.React.useState;") {
+		t.Errorf("expected globals for the external import, got:\n%s", code)
+	}
+	if len(data.Imports) != 0 {
+		t.Errorf("expected the external not to be bundled, got %v", data.Imports)
+	}
+
+	// star imports and require calls resolve to the global too
+	data, err = LoadFile(
+		[]byte("import * as R from 'react';\nvar r = require('react');\nuse(R, r);"),
+		filepath.Join("src", "b.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code = string(data.Code)
+	if !strings.Contains(code, "var R = window.React;") ||
+		!strings.Contains(code, "var r = window.React;") {
+		t.Errorf("expected globals for star import and require, got:\n%s", code)
+	}
+	if len(data.Imports) != 0 || len(data.NamespaceImports) != 0 {
+		t.Errorf("expected no dependencies, got %v %v", data.Imports, data.NamespaceImports)
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	tmp := t.TempDir()
+	componentsDir := filepath.Join(tmp, "src", "components")
+	deepDir := filepath.Join(tmp, "src", "deep")
+	if err := os.MkdirAll(componentsDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(deepDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("export default 1;"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(componentsDir, "Button.js"))
+	write(filepath.Join(deepDir, "util.js"))
+
+	Resolve.Alias = map[string]string{
+		"@components":      componentsDir,
+		"@components/deep": deepDir,
+		"@button":          filepath.Join(componentsDir, "Button.js"),
+	}
+	defer func() { Resolve = ResolveOptions{} }()
+
+	fromDir := filepath.Join(tmp, "src", "pages")
+	resolved := resolveES6ImportPath("@components/Button", fromDir)
+	if resolved != filepath.Join(componentsDir, "Button.js") {
+		t.Errorf("expected the alias to resolve, got %s", resolved)
+	}
+
+	// the longest matching prefix wins
+	resolved = resolveES6ImportPath("@components/deep/util", fromDir)
+	if resolved != filepath.Join(deepDir, "util.js") {
+		t.Errorf("expected the longer alias to win, got %s", resolved)
+	}
+
+	// an exact match can point straight at a file
+	resolved = resolveES6ImportPath("@button", fromDir)
+	if resolved != filepath.Join(componentsDir, "Button.js") {
+		t.Errorf("expected the exact alias to resolve, got %s", resolved)
+	}
+}
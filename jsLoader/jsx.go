@@ -0,0 +1,262 @@
+package jsLoader
+
+import (
+	"strconv"
+	"strings"
+)
+
+// JSXPragma is the function jsx elements compile to, JSXFragment
+// is the component jsx fragments compile to. Empty values mean the
+// React defaults
+var (
+	JSXPragma   string
+	JSXFragment string
+)
+
+func jsxPragma() string {
+	if JSXPragma != "" {
+		return JSXPragma
+	}
+	return "React.createElement"
+}
+
+func jsxFragment() string {
+	if JSXFragment != "" {
+		return JSXFragment
+	}
+	return "React.Fragment"
+}
+
+// jsxParser walks the raw jsx token the lexer produced and builds
+// ordinary call expressions out of it, so the printer, mangler and
+// minifier never see anything jsx-specific
+type jsxParser struct {
+	src []rune
+	pos int
+	// tok is the original token, parse errors in the element point
+	// at it
+	tok token
+}
+
+func parseJSXToken(t token) ast {
+	p := jsxParser{src: []rune(t.lexeme), tok: t}
+	return p.parseElement()
+}
+
+func (p *jsxParser) at(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+func (p *jsxParser) skipSpace() {
+	for p.at(0) == ' ' || p.at(0) == '\t' || p.at(0) == '\r' ||
+		isLineTerminator(p.at(0)) {
+		p.pos++
+	}
+}
+
+func (p *jsxParser) fail(message string) {
+	panic(parseError{message: message, tok: p.tok})
+}
+
+func (p *jsxParser) readName() string {
+	name := ""
+	for isLetter(p.at(0)) || isDigit(p.at(0)) ||
+		p.at(0) == '.' || p.at(0) == '-' {
+		name += string(p.at(0))
+		p.pos++
+	}
+	return name
+}
+
+func (p *jsxParser) readString() string {
+	quote := p.at(0)
+	lexeme := string(quote)
+	p.pos++
+	for p.at(0) != 0 && p.at(0) != quote {
+		lexeme += string(p.at(0))
+		p.pos++
+	}
+	lexeme += string(quote)
+	p.pos++
+	return lexeme
+}
+
+// readExpression consumes a balanced {...} block and returns the
+// source between the braces, reusing the lexer's scanner so
+// strings and nested elements are skipped the same way
+func (p *jsxParser) readExpression() string {
+	l := lexer{source: p.src[p.pos:]}
+	raw := l.consumeJSXExpressionRaw()
+	p.pos += len([]rune(raw))
+	if len(raw) < 2 {
+		p.fail("unterminated jsx expression")
+	}
+	return strings.TrimSpace(raw[1 : len(raw)-1])
+}
+
+// parseExpressionText parses an embedded js expression the same
+// way template interpolations are parsed
+func (p *jsxParser) parseExpressionText(src string) ast {
+	sub := parser{tokens: lex([]byte(src))}
+	return sub.parseExpression(2)
+}
+
+// parseElement parses <tag attrs>children</tag> into a call
+// pragma(tag, props, ...children). The props argument is null when
+// there are no attributes
+func (p *jsxParser) parseElement() ast {
+	p.pos++
+	name := p.readName()
+
+	props := []ast{}
+	selfClosing := false
+
+attributes:
+	for {
+		p.skipSpace()
+		switch {
+		case p.at(0) == 0:
+			p.fail("unterminated jsx element")
+		case p.at(0) == '/' && p.at(1) == '>':
+			p.pos += 2
+			selfClosing = true
+			break attributes
+		case p.at(0) == '>':
+			p.pos++
+			break attributes
+		case p.at(0) == '{':
+			expr := p.readExpression()
+			if !strings.HasPrefix(expr, "...") {
+				p.fail("expected a jsx spread attribute")
+			}
+			props = append(props, makeNode(n_SPREAD, "",
+				p.parseExpressionText(strings.TrimPrefix(expr, "..."))))
+		default:
+			props = append(props, p.parseAttribute())
+		}
+	}
+
+	children := []ast{}
+	if !selfClosing {
+		children = p.parseChildren(name)
+	}
+
+	args := []ast{p.tagExpression(name)}
+	if len(props) > 0 {
+		args = append(args, makeNode(n_OBJECT_LITERAL, "", props...))
+	} else {
+		args = append(args, makeNode(n_IDENTIFIER, "null"))
+	}
+	args = append(args, children...)
+
+	pragma := p.parseExpressionText(jsxPragma())
+	return makeNode(n_CALL, "", append([]ast{pragma}, args...)...)
+}
+
+func (p *jsxParser) parseAttribute() ast {
+	name := p.readName()
+	if name == "" {
+		p.fail("unexpected character in jsx attributes")
+	}
+
+	// names like data-id are not valid identifiers and become
+	// string keys
+	var key ast
+	if strings.Contains(name, "-") {
+		key = makeNode(n_STRING_LITERAL, "'"+name+"'")
+	} else {
+		key = makeNode(n_IDENTIFIER, name)
+	}
+
+	p.skipSpace()
+	if p.at(0) != '=' {
+		// a bare attribute is shorthand for ={true}
+		return makeNode(n_OBJECT_PROP, "", key, makeNode(n_BOOL_LITERAL, "true"))
+	}
+	p.pos++
+	p.skipSpace()
+
+	var value ast
+	switch {
+	case p.at(0) == '"' || p.at(0) == '\'':
+		value = makeNode(n_STRING_LITERAL, p.readString())
+	case p.at(0) == '{':
+		value = p.parseExpressionText(p.readExpression())
+	default:
+		p.fail("expected a jsx attribute value")
+	}
+	return makeNode(n_OBJECT_PROP, "", key, value)
+}
+
+func (p *jsxParser) parseChildren(name string) []ast {
+	children := []ast{}
+	text := ""
+	flush := func() {
+		// whitespace around line breaks is indentation, not
+		// content, so runs of whitespace collapse to one space
+		collapsed := strings.Join(strings.Fields(text), " ")
+		if collapsed != "" {
+			children = append(children,
+				makeNode(n_STRING_LITERAL, strconv.Quote(collapsed)))
+		}
+		text = ""
+	}
+
+	for {
+		switch {
+		case p.at(0) == 0:
+			p.fail("unterminated jsx element <" + name + ">")
+
+		case p.at(0) == '<' && p.at(1) == '/':
+			flush()
+			p.pos += 2
+			closing := p.readName()
+			if closing != name {
+				p.fail("jsx closing tag </" + closing +
+					"> does not match <" + name + ">")
+			}
+			p.skipSpace()
+			if p.at(0) != '>' {
+				p.fail("expected '>' in jsx closing tag")
+			}
+			p.pos++
+			return children
+
+		case p.at(0) == '<':
+			flush()
+			children = append(children, p.parseElement())
+
+		case p.at(0) == '{':
+			flush()
+			expr := p.readExpression()
+			// {/* comment */} children lex to nothing and are
+			// dropped
+			tokens := lex([]byte(expr))
+			if tokens[0].tType != tEOF {
+				sub := parser{tokens: tokens}
+				children = append(children, sub.parseExpression(2))
+			}
+
+		default:
+			text += string(p.at(0))
+			p.pos++
+		}
+	}
+}
+
+// tagExpression builds the first createElement argument: lowercase
+// tags are html elements and stay strings, capitalized or dotted
+// names are component references and parse as expressions
+func (p *jsxParser) tagExpression(name string) ast {
+	if name == "" {
+		return p.parseExpressionText(jsxFragment())
+	}
+	first := []rune(name)[0]
+	if !strings.Contains(name, ".") && (first < 'A' || first > 'Z') {
+		return makeNode(n_STRING_LITERAL, "'"+name+"'")
+	}
+	return p.parseExpressionText(name)
+}
@@ -0,0 +1,66 @@
+package jsLoader
+
+import "testing"
+
+func TestJSXElements(t *testing.T) {
+	expectMinified(t,
+		`var el = <div className="app" onClick={handle}>hello</div>;`,
+		`var el=React.createElement('div',{className:"app",onClick:handle},"hello")`)
+	expectMinified(t,
+		"var el = <br/>;",
+		"var el=React.createElement('br',null)")
+	expectMinified(t,
+		"var el = <App items={list} selected/>;",
+		"var el=React.createElement(App,{items:list,selected:!0})")
+	expectMinified(t,
+		`var el = <Menu.Item data-id="3"/>;`,
+		`var el=React.createElement(Menu.Item,{'data-id':"3"})`)
+	// a '<' after a value is still a comparison
+	expectMinified(t,
+		"var x = a < b;",
+		"var x=a<b")
+}
+
+func TestJSXChildren(t *testing.T) {
+	expectMinified(t,
+		"var el = <ul>{items.map(function(i) { return <li key={i}>{i}</li>; })}</ul>;",
+		"var el=React.createElement('ul',null,items.map(function(i){return React.createElement('li',{key:i},i)}))")
+	// indentation collapses, it is not text content
+	expectMinified(t,
+		"var el = <p>\n  Hello {name}\n  !\n</p>;",
+		`var el=React.createElement('p',null,"Hello",name,"!")`)
+}
+
+func TestJSXFragmentsAndSpread(t *testing.T) {
+	expectMinified(t,
+		"var el = <><a/><b/></>;",
+		"var el=React.createElement(React.Fragment,null,"+
+			"React.createElement('a',null),React.createElement('b',null))")
+	expectMinified(t,
+		`var el = <div {...props} id="x"/>;`,
+		`var el=React.createElement('div',{...props,id:"x"})`)
+}
+
+func TestJSXPragma(t *testing.T) {
+	JSXPragma = "h"
+	JSXFragment = "Fragment"
+	defer func() { JSXPragma, JSXFragment = "", "" }()
+
+	expectMinified(t,
+		"var el = <div>{x}</div>;",
+		"var el=h('div',null,x)")
+	expectMinified(t,
+		"var el = <>{x}</>;",
+		"var el=h(Fragment,null,x)")
+}
+
+func TestJSXTrickyNesting(t *testing.T) {
+	expectMinified(t,
+		"var el = <div>{/* nothing */}</div>;",
+		"var el=React.createElement('div',null)")
+	// the brace in the attribute string must not end the embedded
+	// expression early
+	expectMinified(t,
+		`var el = <div>{cond ? <a title="}"/> : null}</div>;`,
+		`var el=React.createElement('div',null,cond?React.createElement('a',{title:"}"}):null)`)
+}
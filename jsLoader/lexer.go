@@ -0,0 +1,606 @@
+package jsLoader
+
+import "strings"
+
+type tokenType int
+
+const (
+	tUNDEFINED tokenType = iota
+	tEOF
+	tNAME
+	tNUMBER
+	tSTRING
+	tREGEX
+	tTEMPLATE
+	tJSX
+	tPUNCTUATOR
+)
+
+type token struct {
+	tType  tokenType
+	lexeme string
+	line   int
+	column int
+}
+
+// operators have to be sorted from longest to shortest so that
+// maximal munch works with a simple prefix scan
+var operators = []string{
+	">>>=",
+	"===", "!==", ">>>", "**=", "<<=", ">>=", "||=", "&&=", "??=",
+	"**",
+	"==", "!=", "<=", ">=", "&&", "||", "??", "++", "--", "?.",
+	"<<", ">>", "+=", "-=", "*=", "/=", "%=", "&=",
+	"|=", "^=", "=>", "...",
+	"{", "}", "(", ")", "[", "]", ";", ",", "<", ">",
+	"+", "-", "*", "/", "%", "&", "|", "^", "!", "~",
+	"?", ":", "=", ".", "@",
+}
+
+var keywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "continue": true,
+	"debugger": true, "default": true, "delete": true, "do": true,
+	"else": true, "finally": true, "for": true, "function": true,
+	"if": true, "in": true, "instanceof": true, "new": true,
+	"return": true, "switch": true, "this": true, "throw": true,
+	"try": true, "typeof": true, "var": true, "void": true,
+	"while": true, "with": true, "let": true, "const": true,
+	"import": true, "export": true, "from": true, "as": true,
+	"null": true, "true": true, "false": true, "undefined": true,
+}
+
+// PreserveComments keeps every block comment in the output, not
+// just the /*! legal banners that are always retained
+var PreserveComments bool
+
+type lexer struct {
+	source []rune
+	pos    int
+	line   int
+	column int
+	tokens []token
+	// comments collects block comments that have to survive into
+	// the bundle, see skipBlockComment
+	comments []string
+}
+
+func isLetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		c == '_' || c == '$' || (c > 127 && !isLineTerminator(c))
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c rune) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func (l *lexer) at(offset int) rune {
+	if l.pos+offset >= len(l.source) {
+		return 0
+	}
+	return l.source[l.pos+offset]
+}
+
+// isLineTerminator matches everything js counts as a line
+// terminator: \n, \r and the unicode separators U+2028 and U+2029
+func isLineTerminator(c rune) bool {
+	return c == '\n' || c == '\r' || c == '\u2028' || c == '\u2029'
+}
+
+func (l *lexer) advance() {
+	c := l.at(0)
+	switch {
+	case c == '\r' && l.at(1) == '\n':
+		// a \r\n pair is one terminator, the \n half counts the line
+	case isLineTerminator(c):
+		l.line++
+		l.column = 0
+	default:
+		l.column++
+	}
+	l.pos++
+}
+
+func (l *lexer) add(tType tokenType, lexeme string, line, column int) {
+	l.tokens = append(l.tokens, token{tType, lexeme, line, column})
+}
+
+// lastMeaningfulToken is used to decide whether a '/' starts a regex
+// or is a division operator
+func (l *lexer) lastMeaningfulToken() token {
+	if len(l.tokens) == 0 {
+		return token{tType: tUNDEFINED}
+	}
+	return l.tokens[len(l.tokens)-1]
+}
+
+func (l *lexer) regexAllowed() bool {
+	prev := l.lastMeaningfulToken()
+	switch prev.tType {
+	case tUNDEFINED:
+		return true
+	case tNAME:
+		if !keywords[prev.lexeme] || prev.lexeme == "this" {
+			return false
+		}
+		// a keyword right after . or ?. is a property name like
+		// x.in, an expression, so a following / is division
+		if len(l.tokens) >= 2 {
+			before := l.tokens[len(l.tokens)-2]
+			if before.tType == tPUNCTUATOR &&
+				(before.lexeme == "." || before.lexeme == "?.") {
+				return false
+			}
+		}
+		return true
+	case tPUNCTUATOR:
+		// after a closed group or a postfix ++/-- the / divides the
+		// expression that just ended
+		switch prev.lexeme {
+		case ")", "]", "++", "--":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (l *lexer) lexString() {
+	quote := l.at(0)
+	startLine, startColumn := l.line, l.column
+	lexeme := string(quote)
+	l.advance()
+	for l.at(0) != quote && l.at(0) != 0 {
+		if l.at(0) == '\\' {
+			lexeme += string(l.at(0))
+			l.advance()
+		}
+		lexeme += string(l.at(0))
+		l.advance()
+	}
+	lexeme += string(quote)
+	l.advance()
+	l.add(tSTRING, lexeme, startLine, startColumn)
+}
+
+func isBinaryDigit(c rune) bool {
+	return c == '0' || c == '1'
+}
+
+func isOctalDigit(c rune) bool {
+	return c >= '0' && c <= '7'
+}
+
+// consumeDigits reads a run of digits of the given kind, allowing
+// '_' separators between them
+func (l *lexer) consumeDigits(isDigitKind func(rune) bool) string {
+	lexeme := ""
+	for isDigitKind(l.at(0)) || (l.at(0) == '_' && isDigitKind(l.at(1))) {
+		lexeme += string(l.at(0))
+		l.advance()
+	}
+	return lexeme
+}
+
+func (l *lexer) lexNumber() {
+	startLine, startColumn := l.line, l.column
+	lexeme := ""
+
+	prefixed := func(marker byte) bool {
+		c := l.at(1)
+		return l.at(0) == '0' && (c == rune(marker) || c == rune(marker-'a'+'A'))
+	}
+
+	switch {
+	case prefixed('x'):
+		lexeme += string(l.at(0)) + string(l.at(1))
+		l.advance()
+		l.advance()
+		lexeme += l.consumeDigits(isHexDigit)
+
+	case prefixed('b'):
+		lexeme += string(l.at(0)) + string(l.at(1))
+		l.advance()
+		l.advance()
+		lexeme += l.consumeDigits(isBinaryDigit)
+
+	case prefixed('o'):
+		lexeme += string(l.at(0)) + string(l.at(1))
+		l.advance()
+		l.advance()
+		lexeme += l.consumeDigits(isOctalDigit)
+
+	default:
+		lexeme += l.consumeDigits(isDigit)
+		// the decimal point may come without digits on either side,
+		// as in .5 and 5.
+		if l.at(0) == '.' {
+			lexeme += "."
+			l.advance()
+			lexeme += l.consumeDigits(isDigit)
+		}
+		if e := l.at(0); e == 'e' || e == 'E' {
+			sign := l.at(1)
+			if isDigit(sign) || ((sign == '+' || sign == '-') && isDigit(l.at(2))) {
+				lexeme += string(e)
+				l.advance()
+				if sign == '+' || sign == '-' {
+					lexeme += string(sign)
+					l.advance()
+				}
+				lexeme += l.consumeDigits(isDigit)
+			}
+		}
+	}
+
+	l.add(tNUMBER, lexeme, startLine, startColumn)
+}
+
+// lexTemplate consumes a whole template literal, interpolations
+// included, as one raw token. The parser splits it apart later.
+// Raw newlines inside stay untouched
+func (l *lexer) lexTemplate() {
+	startLine, startColumn := l.line, l.column
+	lexeme := l.consumeTemplateRaw()
+	l.add(tTEMPLATE, lexeme, startLine, startColumn)
+}
+
+func (l *lexer) consumeTemplateRaw() string {
+	lexeme := "`"
+	l.advance()
+	for l.at(0) != 0 {
+		c := l.at(0)
+		if c == '\\' {
+			lexeme += string(c)
+			l.advance()
+			lexeme += string(l.at(0))
+			l.advance()
+			continue
+		}
+		if c == '`' {
+			lexeme += "`"
+			l.advance()
+			return lexeme
+		}
+		if c == '$' && l.at(1) == '{' {
+			lexeme += l.consumeInterpolationRaw()
+			continue
+		}
+		lexeme += string(c)
+		l.advance()
+	}
+	return lexeme
+}
+
+func (l *lexer) consumeInterpolationRaw() string {
+	lexeme := "${"
+	l.advance()
+	l.advance()
+	depth := 1
+	for l.at(0) != 0 && depth > 0 {
+		c := l.at(0)
+		switch {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == '\'' || c == '"':
+			quote := c
+			lexeme += string(c)
+			l.advance()
+			for l.at(0) != 0 && l.at(0) != quote {
+				if l.at(0) == '\\' {
+					lexeme += string(l.at(0))
+					l.advance()
+				}
+				lexeme += string(l.at(0))
+				l.advance()
+			}
+		case c == '`':
+			lexeme += l.consumeTemplateRaw()
+			continue
+		}
+		lexeme += string(l.at(0))
+		l.advance()
+	}
+	return lexeme
+}
+
+// lexJSX consumes a whole jsx element, children and all, as one
+// raw token, the same way template literals are handled. The
+// parser rebuilds it into pragma calls later
+func (l *lexer) lexJSX() {
+	startLine, startColumn := l.line, l.column
+	lexeme := l.consumeJSXElementRaw()
+	l.add(tJSX, lexeme, startLine, startColumn)
+}
+
+func (l *lexer) consumeJSXElementRaw() string {
+	lexeme := string(l.at(0))
+	l.advance()
+
+	// the tag name, empty for fragments
+	for isLetter(l.at(0)) || isDigit(l.at(0)) || l.at(0) == '.' || l.at(0) == '-' {
+		lexeme += string(l.at(0))
+		l.advance()
+	}
+
+attributes:
+	for l.at(0) != 0 {
+		c := l.at(0)
+		switch {
+		case c == '"' || c == '\'':
+			lexeme += l.consumeJSXStringRaw()
+		case c == '{':
+			lexeme += l.consumeJSXExpressionRaw()
+		case c == '/' && l.at(1) == '>':
+			l.advance()
+			l.advance()
+			return lexeme + "/>"
+		case c == '>':
+			l.advance()
+			lexeme += ">"
+			break attributes
+		default:
+			lexeme += string(c)
+			l.advance()
+		}
+	}
+
+	for l.at(0) != 0 {
+		c := l.at(0)
+		switch {
+		case c == '{':
+			lexeme += l.consumeJSXExpressionRaw()
+		case c == '<' && l.at(1) == '/':
+			for l.at(0) != 0 && l.at(0) != '>' {
+				lexeme += string(l.at(0))
+				l.advance()
+			}
+			l.advance()
+			return lexeme + ">"
+		case c == '<':
+			lexeme += l.consumeJSXElementRaw()
+		default:
+			lexeme += string(c)
+			l.advance()
+		}
+	}
+	return lexeme
+}
+
+// jsx attribute strings have no escape sequences, a quote always
+// ends the string
+func (l *lexer) consumeJSXStringRaw() string {
+	quote := l.at(0)
+	lexeme := string(quote)
+	l.advance()
+	for l.at(0) != 0 && l.at(0) != quote {
+		lexeme += string(l.at(0))
+		l.advance()
+	}
+	lexeme += string(quote)
+	l.advance()
+	return lexeme
+}
+
+// consumeJSXExpressionRaw consumes a balanced {...} block, skipping
+// over strings and templates so braces inside them do not count.
+// An element nested in the expression is consumed recursively, its
+// attribute strings may contain unbalanced braces too
+func (l *lexer) consumeJSXExpressionRaw() string {
+	lexeme := ""
+	depth := 0
+	var prev rune
+	for l.at(0) != 0 {
+		c := l.at(0)
+		if c == '\'' || c == '"' {
+			lexeme += l.consumeJSXStringRaw()
+			prev = c
+			continue
+		}
+		if c == '`' {
+			lexeme += l.consumeTemplateRaw()
+			prev = c
+			continue
+		}
+		if c == '<' && jsxAllowedAfter(prev) &&
+			(isLetter(l.at(1)) || l.at(1) == '>') {
+			lexeme += l.consumeJSXElementRaw()
+			prev = '>'
+			continue
+		}
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		lexeme += string(c)
+		l.advance()
+		if c != ' ' && c != '\t' && !isLineTerminator(c) {
+			prev = c
+		}
+		if depth == 0 {
+			return lexeme
+		}
+	}
+	return lexeme
+}
+
+// jsxAllowedAfter reports whether a '<' after the given character
+// can start an element rather than a comparison, the rune-level
+// version of regexAllowed
+func jsxAllowedAfter(prev rune) bool {
+	return prev == 0 || strings.ContainsRune("({[,?:;&|=!+-*/%~<>", prev)
+}
+
+// skipLineComment consumes a // comment up to the line terminator,
+// which stays in the input so line counting is untouched
+func (l *lexer) skipLineComment() {
+	for l.at(0) != 0 && !isLineTerminator(l.at(0)) {
+		l.advance()
+	}
+}
+
+// skipBlockComment consumes a /* */ comment, newlines inside go
+// through advance so later token positions stay accurate. Legal
+// banners starting with /*! are kept for the top of the module,
+// licenses require them in the distributed bundle even when it is
+// minified
+func (l *lexer) skipBlockComment() {
+	text := "/*"
+	l.advance()
+	l.advance()
+	for l.at(0) != 0 {
+		if l.at(0) == '*' && l.at(1) == '/' {
+			l.advance()
+			l.advance()
+			text += "*/"
+			break
+		}
+		text += string(l.at(0))
+		l.advance()
+	}
+	if strings.HasPrefix(text, "/*!") || PreserveComments {
+		l.comments = append(l.comments, text)
+	}
+}
+
+func (l *lexer) lexRegex() {
+	startLine, startColumn := l.line, l.column
+	lexeme := "/"
+	l.advance()
+	// a / inside a [...] character class does not close the regex
+	inClass := false
+	for l.at(0) != 0 && !isLineTerminator(l.at(0)) {
+		c := l.at(0)
+		if c == '\\' {
+			lexeme += string(c)
+			l.advance()
+			lexeme += string(l.at(0))
+			l.advance()
+			continue
+		}
+		if c == '[' {
+			inClass = true
+		} else if c == ']' {
+			inClass = false
+		} else if c == '/' && !inClass {
+			break
+		}
+		lexeme += string(c)
+		l.advance()
+	}
+	lexeme += "/"
+	l.advance()
+	for isLetter(l.at(0)) {
+		lexeme += string(l.at(0))
+		l.advance()
+	}
+	l.add(tREGEX, lexeme, startLine, startColumn)
+}
+
+func (l *lexer) lexOperator() bool {
+	for _, op := range operators {
+		matches := true
+		for i, c := range op {
+			if l.at(i) != c {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			l.add(tPUNCTUATOR, op, l.line, l.column)
+			for range op {
+				l.advance()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func lex(src []byte) []token {
+	tokens, _ := lexWithComments(src)
+	return tokens
+}
+
+// lexWithComments also returns the block comments that have to be
+// re-emitted at the top of the module
+func lexWithComments(src []byte) ([]token, []string) {
+	l := lexer{
+		source: []rune(string(src)),
+	}
+
+	// some windows editors prepend a utf-8 byte order mark, it is
+	// not part of the program and does not count as a column
+	if l.at(0) == '\ufeff' {
+		l.pos++
+	}
+
+	// a leading #! line is a node CLI convention, not js syntax.
+	// The terminator stays in the input so line counting is right
+	if l.at(0) == '#' && l.at(1) == '!' {
+		l.skipLineComment()
+	}
+
+	for l.pos < len(l.source) {
+		c := l.at(0)
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || isLineTerminator(c):
+			l.advance()
+
+		case c == '"' || c == '\'':
+			l.lexString()
+
+		case c == '`':
+			l.lexTemplate()
+
+		case isDigit(c) || (c == '.' && isDigit(l.at(1))):
+			l.lexNumber()
+
+		case isLetter(c) || (c == '#' && isLetter(l.at(1))):
+			// private class member names keep their # so they stay
+			// distinct from plain identifiers
+			startLine, startColumn := l.line, l.column
+			lexeme := ""
+			if c == '#' {
+				lexeme = "#"
+				l.advance()
+			}
+			for isLetter(l.at(0)) || isDigit(l.at(0)) {
+				lexeme += string(l.at(0))
+				l.advance()
+			}
+			l.add(tNAME, lexeme, startLine, startColumn)
+
+		// comments win over both division and regex literals, but
+		// not over string contents, which lexString consumes whole
+		case c == '/' && l.at(1) == '/':
+			l.skipLineComment()
+
+		case c == '/' && l.at(1) == '*':
+			l.skipBlockComment()
+
+		case c == '/' && l.regexAllowed():
+			l.lexRegex()
+
+		case c == '<' && l.regexAllowed() && (isLetter(l.at(1)) || l.at(1) == '>'):
+			l.lexJSX()
+
+		default:
+			if !l.lexOperator() {
+				l.add(tPUNCTUATOR, string(c), l.line, l.column)
+				l.advance()
+			}
+		}
+	}
+
+	l.add(tEOF, "", l.line, l.column)
+	return l.tokens, l.comments
+}
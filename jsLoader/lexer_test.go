@@ -0,0 +1,275 @@
+package jsLoader
+
+import (
+	"strings"
+	"testing"
+)
+
+func tokenLexemes(tokens []token) []string {
+	lexemes := []string{}
+	for _, t := range tokens {
+		if t.tType != tEOF {
+			lexemes = append(lexemes, t.lexeme)
+		}
+	}
+	return lexemes
+}
+
+func TestUnicodeLineSeparators(t *testing.T) {
+	// U+2028 between the two statements has to act like a newline
+	src := "var a = 1;\u2028var b = 2;"
+	tokens := lex([]byte(src))
+
+	lexemes := tokenLexemes(tokens)
+	expected := []string{"var", "a", "=", "1", ";", "var", "b", "=", "2", ";"}
+	if len(lexemes) != len(expected) {
+		t.Fatalf("expected %v tokens, got %v: %v", len(expected), len(lexemes), lexemes)
+	}
+	for i, lexeme := range expected {
+		if lexemes[i] != lexeme {
+			t.Errorf("token %v: expected %q, got %q", i, lexeme, lexemes[i])
+		}
+	}
+
+	// the second 'var' sits on line 1 (0-based), column 0
+	secondVar := tokens[5]
+	if secondVar.line != 1 || secondVar.column != 0 {
+		t.Errorf("expected second statement at 1:0, got %v:%v",
+			secondVar.line, secondVar.column)
+	}
+}
+
+func TestNumericLiterals(t *testing.T) {
+	cases := map[string]string{
+		"var a = 1_000_000;":   "1_000_000",
+		"var b = 0b1010_0001;": "0b1010_0001",
+		"var c = 0o777;":       "0o777",
+		"var d = 0xFF_EC;":     "0xFF_EC",
+		"var e = 3.141_592;":   "3.141_592",
+	}
+	for src, expected := range cases {
+		tokens := lex([]byte(src))
+		num := tokens[3]
+		if num.tType != tNUMBER || num.lexeme != expected {
+			t.Errorf("%s: expected number %q, got %q", src, expected, num.lexeme)
+		}
+	}
+}
+
+func TestLineCommentsSkipped(t *testing.T) {
+	src := "var a = 1; // trailing comment\nvar b = 2;"
+	lexemes := tokenLexemes(lex([]byte(src)))
+	expected := []string{"var", "a", "=", "1", ";", "var", "b", "=", "2", ";"}
+	if strings.Join(lexemes, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected comments to be skipped, got %v", lexemes)
+	}
+}
+
+func TestBlockCommentsSkipped(t *testing.T) {
+	src := "a /* not / division */ / b"
+	lexemes := tokenLexemes(lex([]byte(src)))
+	if strings.Join(lexemes, " ") != "a / b" {
+		t.Errorf("expected the block comment to be skipped, got %v", lexemes)
+	}
+}
+
+func TestBlockCommentLineTracking(t *testing.T) {
+	src := "/* spans\nmultiple\nlines */\nvar = ;"
+	_, err := parse(lex([]byte(src)))
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if !strings.Contains(err.Error(), "at 4:") {
+		t.Errorf("expected the error position to account for comment newlines, got %v", err)
+	}
+}
+
+func TestCommentMarkersInsideString(t *testing.T) {
+	tokens := lex([]byte("var url = 'http://example.com';"))
+	if tokens[3].lexeme != "'http://example.com'" {
+		t.Errorf("expected the string to stay intact, got %q", tokens[3].lexeme)
+	}
+}
+
+func TestDivisionIsNotAComment(t *testing.T) {
+	lexemes := tokenLexemes(lex([]byte("a / b / c")))
+	if strings.Join(lexemes, " ") != "a / b / c" {
+		t.Errorf("expected divisions to survive, got %v", lexemes)
+	}
+}
+
+func TestShebangLine(t *testing.T) {
+	src := "#!/usr/bin/env node\nvar a = 1;"
+	lexemes := tokenLexemes(lex([]byte(src)))
+	expected := []string{"var", "a", "=", "1", ";"}
+	if strings.Join(lexemes, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected the shebang to be stripped, got %v", lexemes)
+	}
+
+	// positions after the shebang still count its line
+	_, err := parse(lex([]byte("#!/usr/bin/env node\nvar = ;")))
+	if err == nil || !strings.Contains(err.Error(), "at 2:") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+
+	// a # further into the file is not a shebang
+	lexemes = tokenLexemes(lex([]byte("a\n#!b")))
+	if len(lexemes) != 4 {
+		t.Errorf("expected '#!' tokens mid-file, got %v", lexemes)
+	}
+}
+
+func TestBannerCommentsPreserved(t *testing.T) {
+	src := []byte("/*! MIT License */\n/* ordinary */\nvar a = 1;")
+	data, err := LoadFile(src, "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(data.Code)
+	if !strings.HasPrefix(code, "/*! MIT License */\n") {
+		t.Errorf("expected the banner at the top of the module, got %s", code)
+	}
+	if strings.Contains(code, "ordinary") {
+		t.Errorf("expected ordinary comments to be dropped, got %s", code)
+	}
+}
+
+func TestBannerSurvivesMinify(t *testing.T) {
+	Minify = true
+	defer func() { Minify = false }()
+
+	data, err := LoadFile([]byte("/*! keep me */ var a = 1;"), "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data.Code), "/*! keep me */") {
+		t.Errorf("expected the banner in minified output, got %s", data.Code)
+	}
+}
+
+func TestPreserveCommentsOption(t *testing.T) {
+	PreserveComments = true
+	defer func() { PreserveComments = false }()
+
+	data, err := LoadFile([]byte("/* ordinary */ var a = 1;"), "src/lib.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data.Code), "/* ordinary */") {
+		t.Errorf("expected the comment to be kept, got %s", data.Code)
+	}
+}
+
+func TestLineSeparatorPreservedInString(t *testing.T) {
+	src := "var a = 'x\u2028y';"
+	tokens := lex([]byte(src))
+
+	str := tokens[3]
+	if str.tType != tSTRING {
+		t.Fatalf("expected string token, got %v %q", str.tType, str.lexeme)
+	}
+	if str.lexeme != "'x\u2028y'" {
+		t.Errorf("string content changed: %q", str.lexeme)
+	}
+}
+
+func TestCRLFLineEndings(t *testing.T) {
+	// \r\n counts as a single newline for positions
+	tokens := lex([]byte("var a = 1;\r\nvar b = 2;\r\n"))
+	secondVar := tokens[5]
+	if secondVar.lexeme != "var" || secondVar.line != 1 || secondVar.column != 0 {
+		t.Errorf("expected the second statement at 1:0, got %q at %v:%v",
+			secondVar.lexeme, secondVar.line, secondVar.column)
+	}
+
+	// a lone \r is a terminator too
+	tokens = lex([]byte("a\rb"))
+	if tokens[1].lexeme != "b" || tokens[1].line != 1 {
+		t.Errorf("expected b on line 1 after a lone \\r, got %q on line %v",
+			tokens[1].lexeme, tokens[1].line)
+	}
+
+	// the newline separates the statements through ASI
+	expectMinified(t, "a = 1\r\nb = 2", "a=1;b=2")
+}
+
+func TestLeadingBOM(t *testing.T) {
+	// a utf-8 byte order mark before the source is skipped and does
+	// not shift the first token's position
+	tokens := lex([]byte("\ufeffvar a = 1;"))
+	first := tokens[0]
+	if first.lexeme != "var" || first.line != 0 || first.column != 0 {
+		t.Errorf("expected var at 0:0 after a BOM, got %q at %v:%v",
+			first.lexeme, first.line, first.column)
+	}
+
+	// a BOM-only file is just an empty program
+	if tokens := lex([]byte("\ufeff")); tokens[0].tType != tEOF {
+		t.Errorf("expected only EOF for a BOM-only file, got %v", tokens)
+	}
+
+	expectMinified(t, "\ufeffa = 1", "a=1")
+}
+
+func TestNumberForms(t *testing.T) {
+	// every form is one tNUMBER token with the spelling intact
+	for _, src := range []string{"1e10", "1.5e-3", "1E+2", ".5", "5.", "1_000e3"} {
+		tokens := lex([]byte(src))
+		if tokens[0].tType != tNUMBER || tokens[0].lexeme != src {
+			t.Errorf("expected %s to lex as one number, got %v", src, tokens)
+		}
+	}
+
+	// a leading dot only starts a number before a digit
+	tokens := lex([]byte("a.b"))
+	if len(tokens) != 4 || tokens[1].lexeme != "." {
+		t.Errorf("expected a.b to stay a member access, got %v", tokens)
+	}
+
+	expectMinified(t, "var x = .5 + 1e3;", "var x=.5+1e3")
+	expectMinified(t, "1..toString()", "1..toString()")
+}
+
+func TestRegexCharacterClasses(t *testing.T) {
+	// a / inside a character class or behind an escape does not end
+	// the pattern
+	for _, src := range []string{`/a\/b/g`, `/[/]/`, `/[^/]*/`, `/[a/b]/i`} {
+		tokens := lex([]byte("x = " + src))
+		last := tokens[2]
+		if last.tType != tREGEX || last.lexeme != src {
+			t.Errorf("expected %s to lex as one regex, got %v", src, tokens)
+		}
+	}
+}
+
+func TestRegexVersusDivision(t *testing.T) {
+	// division after a value...
+	for _, src := range []string{
+		"a / b / c", "(a) / b", "x[0] / 2", "a++ / 2", "1 / 2",
+	} {
+		for _, tok := range lex([]byte(src)) {
+			if tok.tType == tREGEX {
+				t.Errorf("%s: expected only division, lexed regex %q", src, tok.lexeme)
+			}
+		}
+	}
+
+	// ...a regex where an expression is expected
+	for _, src := range []string{
+		"return /re/.test(x)", "x = /re/", "f(/re/, 2)", "a && /re/",
+		"typeof /re/", "/re/.test(x)",
+	} {
+		found := false
+		for _, tok := range lex([]byte(src)) {
+			if tok.tType == tREGEX && tok.lexeme == "/re/" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected a /re/ regex token, got %v",
+				src, tokenLexemes(lex([]byte(src))))
+		}
+	}
+
+	expectMinified(t, "var x = a / b / c;", "var x=a/b/c")
+}
@@ -0,0 +1,272 @@
+package jsLoader
+
+// Mangle renames local variables and parameters to short names in
+// the transformed output. Only bindings declared inside the module
+// are renamed, so the module-prefixed export/import variables and
+// globals stay untouched
+var Mangle bool
+
+type mangler struct {
+	// reserved holds every identifier that appears anywhere in the
+	// file, generated names never collide with any of them
+	reserved map[string]bool
+}
+
+// scope is one level of the binding chain, the module itself is
+// the outermost scope and every function body adds another one
+type scope struct {
+	parent  *scope
+	renames map[string]string
+	m       *mangler
+}
+
+func (s *scope) child() *scope {
+	return &scope{parent: s, renames: map[string]string{}, m: s.m}
+}
+
+func (s *scope) lookup(name string) (string, bool) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if renamed, ok := sc.renames[name]; ok {
+			return renamed, true
+		}
+	}
+	return "", false
+}
+
+// taken tells whether a generated name is already used anywhere up
+// the scope chain, using it again could capture an outer binding
+func (s *scope) taken(name string) bool {
+	for sc := s; sc != nil; sc = sc.parent {
+		for _, renamed := range sc.renames {
+			if renamed == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *scope) declare(name string) {
+	if _, ok := s.renames[name]; ok {
+		return
+	}
+	for i := 0; ; i++ {
+		candidate := shortName(i)
+		if s.m.reserved[candidate] || keywords[candidate] || s.taken(candidate) {
+			continue
+		}
+		s.renames[name] = candidate
+		return
+	}
+}
+
+// shortName maps an index to a, b, ..., z, aa, ab, ...
+func shortName(i int) string {
+	name := ""
+	for {
+		name = string(rune('a'+i%26)) + name
+		i = i/26 - 1
+		if i < 0 {
+			return name
+		}
+	}
+}
+
+// mangleProgram renames every binding declared in the module to a
+// short identifier
+func mangleProgram(program ast) ast {
+	m := &mangler{reserved: map[string]bool{}}
+	m.collectReserved(program)
+
+	sc := &scope{renames: map[string]string{}, m: m}
+	for _, s := range program.children {
+		m.hoist(s, sc)
+	}
+	for i, s := range program.children {
+		program.children[i] = m.renameNode(s, sc)
+	}
+	return program
+}
+
+func (m *mangler) collectReserved(n ast) {
+	if n.t == n_IDENTIFIER {
+		m.reserved[n.value] = true
+	}
+	for _, c := range n.children {
+		m.collectReserved(c)
+	}
+}
+
+// declarePattern declares every identifier bound by a declarator
+// target or parameter, including array patterns and defaults
+func (m *mangler) declarePattern(target ast, sc *scope) {
+	switch target.t {
+	case n_IDENTIFIER:
+		sc.declare(target.value)
+	case n_ASSIGNMENT:
+		m.declarePattern(target.children[0], sc)
+	case n_SPREAD:
+		m.declarePattern(target.children[0], sc)
+	case n_ARRAY_LITERAL:
+		for _, c := range target.children {
+			m.declarePattern(c, sc)
+		}
+	case n_OBJECT_LITERAL:
+		for _, c := range target.children {
+			m.declarePattern(c, sc)
+		}
+	case n_OBJECT_PROP:
+		// the key is a property name, only the value side binds
+		m.declarePattern(target.children[1], sc)
+	}
+}
+
+// hoist collects the names a statement declares into the enclosing
+// function scope, without descending into nested functions. This
+// runs before renaming so uses that precede declarations resolve
+func (m *mangler) hoist(n ast, sc *scope) {
+	switch n.t {
+	case n_VAR_STATEMENT:
+		for _, d := range n.children {
+			m.declarePattern(d.children[0], sc)
+		}
+	case n_FUNCTION_DECLARATION, n_CLASS_DECLARATION:
+		if n.children[0].t == n_IDENTIFIER {
+			sc.declare(n.children[0].value)
+		}
+	case n_BLOCK_STATEMENT:
+		for _, c := range n.children {
+			m.hoist(c, sc)
+		}
+	case n_IF_STATEMENT:
+		m.hoist(n.children[1], sc)
+		m.hoist(n.children[2], sc)
+	case n_FOR_STATEMENT:
+		m.hoist(n.children[0], sc)
+		m.hoist(n.children[3], sc)
+	case n_FOR_IN_STATEMENT:
+		m.hoist(n.children[0], sc)
+		m.hoist(n.children[2], sc)
+	case n_WHILE_STATEMENT:
+		m.hoist(n.children[1], sc)
+	case n_DO_WHILE_STATEMENT:
+		m.hoist(n.children[1], sc)
+	case n_TRY_STATEMENT:
+		m.hoist(n.children[0], sc)
+		m.hoist(n.children[2], sc)
+		m.hoist(n.children[3], sc)
+	case n_SWITCH_STATEMENT:
+		for _, c := range n.children[1:] {
+			for _, s := range c.children[1:] {
+				m.hoist(s, sc)
+			}
+		}
+	}
+}
+
+// enterFunction makes the scope for a function body, declaring its
+// parameters and hoisted bindings, and renames params and body
+func (m *mangler) enterFunction(params, body ast, sc *scope) (ast, ast) {
+	fnScope := sc.child()
+	for _, param := range params.children {
+		m.declarePattern(param, fnScope)
+	}
+	if body.t == n_BLOCK_STATEMENT {
+		for _, s := range body.children {
+			m.hoist(s, fnScope)
+		}
+	}
+	return m.renameNode(params, fnScope), m.renameNode(body, fnScope)
+}
+
+func (m *mangler) renameChildren(n ast, sc *scope) ast {
+	for i, c := range n.children {
+		n.children[i] = m.renameNode(c, sc)
+	}
+	return n
+}
+
+func (m *mangler) renameNode(n ast, sc *scope) ast {
+	switch n.t {
+	case n_IDENTIFIER:
+		if renamed, ok := sc.lookup(n.value); ok {
+			n.value = renamed
+		}
+		return n
+
+	case n_FUNCTION_DECLARATION:
+		// the name lives in the enclosing scope, hoist put it there
+		n.children[0] = m.renameNode(n.children[0], sc)
+		n.children[1], n.children[2] = m.enterFunction(n.children[1], n.children[2], sc)
+		return n
+
+	case n_FUNCTION_EXPRESSION:
+		fnScope := sc.child()
+		if n.children[0].t == n_IDENTIFIER {
+			// a function expression's name is only visible inside
+			fnScope.declare(n.children[0].value)
+			n.children[0] = m.renameNode(n.children[0], fnScope)
+		}
+		n.children[1], n.children[2] = m.enterFunction(n.children[1], n.children[2], fnScope)
+		return n
+
+	case n_ARROW_FUNCTION:
+		n.children[0], n.children[1] = m.enterFunction(n.children[0], n.children[1], sc)
+		return n
+
+	case n_CLASS_METHOD:
+		// the method name is a property, not a binding. A computed
+		// key is an expression, so it does get renamed
+		if n.children[0].t == n_COMPUTED_KEY {
+			n.children[0] = m.renameNode(n.children[0], sc)
+		}
+		n.children[1], n.children[2] = m.enterFunction(n.children[1], n.children[2], sc)
+		return n
+
+	case n_CLASS_FIELD:
+		// the field name is a property too, only the initializer is
+		// renamed
+		if n.children[0].t == n_COMPUTED_KEY {
+			n.children[0] = m.renameNode(n.children[0], sc)
+		}
+		n.children[1] = m.renameNode(n.children[1], sc)
+		return n
+
+	case n_MEMBER:
+		n.children[0] = m.renameNode(n.children[0], sc)
+		if n.value == "[" || n.value == "?.[" {
+			n.children[1] = m.renameNode(n.children[1], sc)
+		}
+		return n
+
+	case n_OBJECT_PROP:
+		// keys are property names, only the value side is renamed.
+		// A computed key is an expression, so it does get renamed
+		if n.children[0].t == n_COMPUTED_KEY {
+			n.children[0] = m.renameNode(n.children[0], sc)
+		}
+		n.children[1] = m.renameNode(n.children[1], sc)
+		return n
+
+	case n_OBJECT_METHOD:
+		if n.children[0].t == n_COMPUTED_KEY {
+			n.children[0] = m.renameNode(n.children[0], sc)
+		}
+		n.children[1], n.children[2] = m.enterFunction(n.children[1], n.children[2], sc)
+		return n
+
+	case n_TRY_STATEMENT:
+		n.children[0] = m.renameNode(n.children[0], sc)
+		if n.children[1].t != n_EMPTY {
+			catchScope := sc.child()
+			m.declarePattern(n.children[1], catchScope)
+			n.children[1] = m.renameNode(n.children[1], catchScope)
+			n.children[2] = m.renameNode(n.children[2], catchScope)
+		}
+		n.children[3] = m.renameNode(n.children[3], sc)
+		return n
+
+	default:
+		return m.renameChildren(n, sc)
+	}
+}
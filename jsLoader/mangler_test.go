@@ -0,0 +1,59 @@
+package jsLoader
+
+import (
+	"testing"
+)
+
+// expectMangled parses src, runs the mangler and checks the output
+func expectMangled(t *testing.T, src, expected string) {
+	t.Helper()
+	program, err := parse(lex([]byte(src)))
+	if err != nil {
+		t.Fatalf("parse error in %q: %v", src, err)
+	}
+	printed := printAst(mangleProgram(program))
+	if printed != expected {
+		t.Errorf("mangle mismatch:\nsource:   %s\nexpected: %s\ngot:      %s",
+			src, expected, printed)
+	}
+}
+
+func TestMangleRenamesLocals(t *testing.T) {
+	expectMangled(t,
+		"var counter = 0; counter++;",
+		"var a = 0;\na++;")
+	expectMangled(t,
+		"function add(first, second) { return first + second; }",
+		"function a(b, c) {\n  return b + c;\n}")
+}
+
+func TestMangleKeepsGlobalsAndProperties(t *testing.T) {
+	expectMangled(t,
+		"var result = compute(); console.log(result.longName);",
+		"var a = compute();\nconsole.log(a.longName);")
+	expectMangled(t,
+		"var obj = {longName: 1}; use(obj.longName);",
+		"var a = {longName: 1};\nuse(a.longName);")
+}
+
+func TestMangleScopes(t *testing.T) {
+	expectMangled(t,
+		"var outer = 1; function f() { var inner = outer; return inner; }",
+		"var a = 1;\nfunction b() {\n  var c = a;\n  return c;\n}")
+	expectMangled(t,
+		"try { f(); } catch (error) { log(error); }",
+		"try {\n  f();\n} catch (a) {\n  log(a);\n}")
+}
+
+func TestMangleHoisting(t *testing.T) {
+	expectMangled(t,
+		"use(helper); function helper() {}",
+		"use(a);\nfunction a() {\n}")
+}
+
+func TestMangleSkipsUsedShortNames(t *testing.T) {
+	// 'a' and 'b' appear in the source, generated names skip them
+	expectMangled(t,
+		"var value = a + b;",
+		"var c = a + b;")
+}
@@ -0,0 +1,1548 @@
+package jsLoader
+
+import (
+	"fmt"
+	"strings"
+)
+
+type astType int
+
+const (
+	n_EMPTY astType = iota
+	n_PROGRAM
+
+	n_IDENTIFIER
+	n_NUMBER_LITERAL
+	n_STRING_LITERAL
+	n_REGEX_LITERAL
+	n_BOOL_LITERAL
+	n_UNDEFINED
+	n_TEMPLATE_LITERAL
+	n_TEMPLATE_STRING
+	n_TAGGED_TEMPLATE
+
+	n_ASSIGNMENT
+	n_CONDITIONAL
+	n_BINARY
+	n_PREFIX_UNARY
+	n_POSTFIX_UNARY
+	n_MEMBER
+	n_CALL
+	n_NEW
+	n_SEQUENCE
+	n_PAREN
+	n_META_PROPERTY
+
+	n_SPREAD
+
+	n_OBJECT_LITERAL
+	n_OBJECT_PROP
+	n_OBJECT_METHOD
+	n_COMPUTED_KEY
+	n_ARRAY_LITERAL
+
+	n_FUNCTION_DECLARATION
+	n_FUNCTION_EXPRESSION
+	n_ARROW_FUNCTION
+	n_PARAM_LIST
+
+	n_VAR_STATEMENT
+	n_DECLARATOR
+	n_EXPRESSION_STATEMENT
+	n_BLOCK_STATEMENT
+	n_IF_STATEMENT
+	n_FOR_STATEMENT
+	n_FOR_IN_STATEMENT
+	n_WHILE_STATEMENT
+	n_DO_WHILE_STATEMENT
+	n_RETURN_STATEMENT
+	n_BREAK_STATEMENT
+	n_CONTINUE_STATEMENT
+	n_THROW_STATEMENT
+	n_TRY_STATEMENT
+	n_SWITCH_STATEMENT
+	n_SWITCH_CASE
+	n_EMPTY_STATEMENT
+	n_DEBUGGER_STATEMENT
+	n_LABELED_STATEMENT
+
+	n_CLASS_DECLARATION
+	n_CLASS_EXPRESSION
+	n_CLASS_METHOD
+	n_CLASS_FIELD
+	n_DECORATOR
+
+	n_IMPORT_STATEMENT
+	n_IMPORT_CALL
+	n_IMPORT_DEFAULT
+	n_IMPORT_STAR
+	n_IMPORT_NAMED
+	n_EXPORT_STATEMENT
+	n_EXPORT_DEFAULT
+	n_EXPORT_NAMED
+	n_EXPORT_DECLARATION
+	n_EXPORT_ALL
+)
+
+type ast struct {
+	t        astType
+	value    string
+	children []ast
+	// original source position, tracked for statements so the
+	// printer can emit source map entries
+	srcLine int
+	srcCol  int
+	hasPos  bool
+}
+
+func makeNode(t astType, value string, children ...ast) ast {
+	return ast{t: t, value: value, children: children}
+}
+
+var emptyNode = ast{t: n_EMPTY}
+
+type parseError struct {
+	message string
+	tok     token
+	// frame is the rendered code frame around the error, attached
+	// by the loader since only it has the source text
+	frame string
+}
+
+func (e parseError) Error() string {
+	msg := fmt.Sprintf("parse error at %v:%v: %s, got '%s'",
+		e.tok.line+1, e.tok.column+1, e.message, e.tok.lexeme)
+	if e.frame != "" {
+		msg += "\n" + e.frame
+	}
+	return msg
+}
+
+// codeFrame renders the source line of an error with a caret under
+// the column and a line of context on each side:
+//
+//	  1 | var a = 1;
+//	> 2 | var = 2;
+//	    |     ^
+//	  3 | var b = 3;
+func codeFrame(src []byte, line, column int) string {
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	from := line - 1
+	if from < 0 {
+		from = 0
+	}
+	to := line + 1
+	if to > len(lines)-1 {
+		to = len(lines) - 1
+	}
+
+	width := len(fmt.Sprint(to + 1))
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		marker := "  "
+		if i == line {
+			marker = "> "
+		}
+		fmt.Fprintf(&sb, "%s%*d | %s\n", marker, width, i+1, lines[i])
+		if i == line {
+			// tabs in the source line are copied into the padding so
+			// the caret stays aligned however they are displayed
+			pad := []rune{}
+			for j, c := range []rune(lines[i]) {
+				if j >= column {
+					break
+				}
+				if c != '\t' {
+					c = ' '
+				}
+				pad = append(pad, c)
+			}
+			fmt.Fprintf(&sb, "  %*s | %s^\n", width, "", string(pad))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// withCodeFrames attaches code frames rendered from src to parse
+// errors that came out of parse
+func withCodeFrames(err error, src []byte) error {
+	switch e := err.(type) {
+	case parseError:
+		e.frame = codeFrame(src, e.tok.line, e.tok.column)
+		return e
+	case parseErrors:
+		for i := range e {
+			e[i].frame = codeFrame(src, e[i].tok.line, e[i].tok.column)
+		}
+		return e
+	}
+	return err
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	// ts allows typescript type syntax, the types are discarded
+	// during parsing, see typescript.go
+	ts bool
+}
+
+// binary operator precedences, assignment and conditional are
+// handled separately in parseExpression
+var binaryPrecedence = map[string]int{
+	"??": 4, "||": 4, "&&": 5,
+	"|": 6, "^": 7, "&": 8,
+	"==": 9, "!=": 9, "===": 9, "!==": 9,
+	"<": 10, ">": 10, "<=": 10, ">=": 10,
+	"in": 10, "instanceof": 10,
+	"<<": 11, ">>": 11, ">>>": 11,
+	"+": 12, "-": 12,
+	"*": 13, "/": 13, "%": 13,
+	"**": 14,
+}
+
+// rightAssociative binary operators group from the right, so
+// a ** b ** c is a ** (b ** c)
+var rightAssociative = map[string]bool{
+	"**": true,
+}
+
+var assignmentOperators = map[string]bool{
+	"=": true, "+=": true, "-=": true, "*=": true, "/=": true,
+	"%=": true, "<<=": true, ">>=": true, ">>>=": true,
+	"&=": true, "|=": true, "^=": true, "**=": true,
+	"||=": true, "&&=": true, "??=": true,
+}
+
+func (p *parser) tok() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) lexeme() string {
+	return p.tokens[p.pos].lexeme
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(lexeme string) bool {
+	if p.lexeme() == lexeme && p.tok().tType != tSTRING {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(lexeme string) {
+	if !p.accept(lexeme) {
+		panic(parseError{message: "expected '" + lexeme + "'", tok: p.tok()})
+	}
+}
+
+func (p *parser) expectType(tType tokenType, what string) token {
+	if p.tok().tType != tType {
+		panic(parseError{message: "expected " + what, tok: p.tok()})
+	}
+	return p.next()
+}
+
+// parseErrors is every syntax error found in one file, reported
+// together so several problems can be fixed per rebuild
+type parseErrors []parseError
+
+func (e parseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, pe := range e {
+		messages[i] = pe.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// parse builds the program ast. A statement that fails to parse is
+// recorded and parsing resumes at the next statement boundary, so
+// one pass collects every syntax error in the file
+func parse(tokens []token) (ast, error) {
+	return parseProgram(tokens, false)
+}
+
+// parseTypeScript parses a module with type annotations allowed,
+// only the runtime-relevant code ends up in the tree
+func parseTypeScript(tokens []token) (ast, error) {
+	return parseProgram(tokens, true)
+}
+
+func parseProgram(tokens []token, ts bool) (ast, error) {
+	p := parser{tokens: tokens, ts: ts}
+	statements := []ast{}
+	errs := parseErrors{}
+	for p.tok().tType != tEOF {
+		s, perr := p.parseStatementRecovering()
+		if perr != nil {
+			errs = append(errs, *perr)
+			p.skipToStatementBoundary()
+			continue
+		}
+		statements = append(statements, s)
+	}
+	if len(errs) > 0 {
+		return emptyNode, errs
+	}
+	return makeNode(n_PROGRAM, "", statements...), nil
+}
+
+func (p *parser) parseStatementRecovering() (s ast, perr *parseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			if pe, ok := r.(parseError); ok {
+				perr = &pe
+				return
+			}
+			panic(r)
+		}
+	}()
+	return p.parseStatement(), nil
+}
+
+// skipToStatementBoundary consumes the rest of a failed statement:
+// everything up to a ';' or closing '}' outside any brackets opened
+// since the error, so the parser can try the next statement
+func (p *parser) skipToStatementBoundary() {
+	depth := 0
+	for p.tok().tType != tEOF {
+		t := p.next()
+		if t.tType != tPUNCTUATOR {
+			continue
+		}
+		switch t.lexeme {
+		case "{", "(", "[":
+			depth++
+		case ")", "]":
+			if depth > 0 {
+				depth--
+			}
+		case "}":
+			if depth > 0 {
+				depth--
+			}
+			if depth == 0 {
+				return
+			}
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (p *parser) parseStatement() ast {
+	start := p.tok()
+	s := p.parseStatementInner()
+	s.srcLine = start.line
+	s.srcCol = start.column
+	s.hasPos = true
+	return s
+}
+
+func (p *parser) parseStatementInner() ast {
+	switch p.lexeme() {
+	case ";":
+		p.next()
+		return makeNode(n_EMPTY_STATEMENT, "")
+	case "{":
+		return p.parseBlock()
+	case "var", "let", "const":
+		s := p.parseVarStatement()
+		p.accept(";")
+		return s
+	case "function":
+		return p.parseFunctionDeclaration()
+	case "async":
+		if p.tokens[p.pos+1].lexeme == "function" {
+			p.next()
+			decl := p.parseFunctionDeclaration()
+			decl.value = "async" + decl.value
+			return decl
+		}
+	case "class":
+		return p.parseClass(n_CLASS_DECLARATION)
+	case "@":
+		return p.parseDecorated(func() ast {
+			return p.parseClass(n_CLASS_DECLARATION)
+		})
+	case "interface":
+		if p.ts && p.tokens[p.pos+1].tType == tNAME {
+			return p.parseInterfaceDeclaration()
+		}
+	case "type":
+		if p.isTypeAliasAhead() {
+			return p.parseTypeAlias()
+		}
+	case "if":
+		return p.parseIf()
+	case "for":
+		return p.parseFor()
+	case "while":
+		return p.parseWhile()
+	case "do":
+		return p.parseDoWhile()
+	case "return":
+		p.next()
+		arg := emptyNode
+		if p.lexeme() != ";" && p.lexeme() != "}" && p.tok().tType != tEOF {
+			arg = p.parseExpression(1)
+		}
+		p.accept(";")
+		return makeNode(n_RETURN_STATEMENT, "", arg)
+	case "break":
+		keyword := p.next()
+		label := p.parseJumpLabel(keyword)
+		p.accept(";")
+		return makeNode(n_BREAK_STATEMENT, label)
+	case "continue":
+		keyword := p.next()
+		label := p.parseJumpLabel(keyword)
+		p.accept(";")
+		return makeNode(n_CONTINUE_STATEMENT, label)
+	case "debugger":
+		p.next()
+		p.accept(";")
+		return makeNode(n_DEBUGGER_STATEMENT, "")
+	case "throw":
+		p.next()
+		arg := p.parseExpression(1)
+		p.accept(";")
+		return makeNode(n_THROW_STATEMENT, "", arg)
+	case "try":
+		return p.parseTry()
+	case "switch":
+		return p.parseSwitch()
+	case "import":
+		// import( and import. are expressions, everything else
+		// starts an import declaration
+		if next := p.tokens[p.pos+1].lexeme; next != "(" && next != "." {
+			return p.parseImport()
+		}
+	case "export":
+		return p.parseExport()
+	}
+
+	// 'name:' starts a labeled statement, nothing else puts a colon
+	// right after the first token of a statement
+	if p.tok().tType == tNAME && !keywords[p.lexeme()] &&
+		p.tokens[p.pos+1].lexeme == ":" {
+		label := p.next().lexeme
+		p.next()
+		return makeNode(n_LABELED_STATEMENT, label, p.parseStatement())
+	}
+
+	expr := p.parseExpression(0)
+	p.accept(";")
+	return makeNode(n_EXPRESSION_STATEMENT, "", expr)
+}
+
+// parseJumpLabel reads the optional label after break/continue. A
+// line break before the label ends the statement instead, the way
+// automatic semicolon insertion does
+func (p *parser) parseJumpLabel(keyword token) string {
+	t := p.tok()
+	if t.tType == tNAME && !keywords[t.lexeme] && t.line == keyword.line {
+		p.next()
+		return t.lexeme
+	}
+	return ""
+}
+
+func (p *parser) parseBlock() ast {
+	p.expect("{")
+	statements := []ast{}
+	for p.lexeme() != "}" && p.tok().tType != tEOF {
+		statements = append(statements, p.parseStatement())
+	}
+	p.expect("}")
+	return makeNode(n_BLOCK_STATEMENT, "", statements...)
+}
+
+func (p *parser) parseVarStatement() ast {
+	kind := p.next().lexeme
+	declarators := []ast{}
+	for {
+		name := p.parseBindingTarget()
+		if p.ts {
+			// a '!' definite assignment assertion may come before
+			// the annotation
+			p.accept("!")
+		}
+		p.skipTypeAnnotation()
+		value := emptyNode
+		if p.accept("=") {
+			value = p.parseExpression(2)
+		}
+		declarators = append(declarators, makeNode(n_DECLARATOR, "", name, value))
+		if !p.accept(",") {
+			break
+		}
+	}
+	return makeNode(n_VAR_STATEMENT, kind, declarators...)
+}
+
+func (p *parser) parseBindingTarget() ast {
+	if p.lexeme() == "[" {
+		return p.parseArrayPattern()
+	}
+	if p.lexeme() == "{" {
+		return p.parseObjectPattern()
+	}
+	t := p.expectType(tNAME, "identifier")
+	return makeNode(n_IDENTIFIER, t.lexeme)
+}
+
+// parseArrayPattern parses an array destructuring target. The
+// result reuses the array literal node since it prints the same
+func (p *parser) parseArrayPattern() ast {
+	p.expect("[")
+	elems := []ast{}
+	for p.lexeme() != "]" && p.tok().tType != tEOF {
+		if p.lexeme() == "," {
+			elems = append(elems, emptyNode)
+			p.next()
+			continue
+		}
+		if p.accept("...") {
+			elems = append(elems, makeNode(n_SPREAD, "", p.parseBindingTarget()))
+		} else {
+			target := p.parseBindingTarget()
+			if p.accept("=") {
+				target = makeNode(n_ASSIGNMENT, "=", target, p.parseExpression(2))
+			}
+			elems = append(elems, target)
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("]")
+	return makeNode(n_ARRAY_LITERAL, "", elems...)
+}
+
+// parseObjectPattern parses an object destructuring target. Like
+// array patterns, the result reuses the literal nodes since it
+// prints the same
+func (p *parser) parseObjectPattern() ast {
+	p.expect("{")
+	props := []ast{}
+	for p.lexeme() != "}" && p.tok().tType != tEOF {
+		if p.accept("...") {
+			// rest only binds a plain name and has to come last
+			t := p.expectType(tNAME, "identifier")
+			props = append(props, makeNode(n_SPREAD, "",
+				makeNode(n_IDENTIFIER, t.lexeme)))
+			break
+		}
+		key := makeNode(n_IDENTIFIER, p.expectType(tNAME, "identifier").lexeme)
+		target := key
+		if p.accept(":") {
+			target = p.parseBindingTarget()
+		}
+		if p.accept("=") {
+			target = makeNode(n_ASSIGNMENT, "=", target, p.parseExpression(2))
+		}
+		props = append(props, makeNode(n_OBJECT_PROP, "", key, target))
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("}")
+	return makeNode(n_OBJECT_LITERAL, "", props...)
+}
+
+func (p *parser) parseFunctionDeclaration() ast {
+	p.expect("function")
+	generator := ""
+	if p.accept("*") {
+		generator = "*"
+	}
+	name := makeNode(n_IDENTIFIER, p.expectType(tNAME, "function name").lexeme)
+	if p.ts && p.lexeme() == "<" {
+		p.skipTypeArgs()
+	}
+	params := p.parseParams()
+	p.skipTypeAnnotation()
+	body := p.parseBlock()
+	return makeNode(n_FUNCTION_DECLARATION, generator, name, params, body)
+}
+
+func (p *parser) parseParams() ast {
+	p.expect("(")
+	params := []ast{}
+	for p.lexeme() != ")" && p.tok().tType != tEOF {
+		var param ast
+		if p.accept("...") {
+			param = makeNode(n_SPREAD, "", p.parseBindingTarget())
+			p.skipTypeAnnotation()
+		} else {
+			param = p.parseBindingTarget()
+			if p.ts {
+				// '?' marks an optional parameter
+				p.accept("?")
+			}
+			p.skipTypeAnnotation()
+			if p.accept("=") {
+				def := p.parseExpression(2)
+				param = makeNode(n_ASSIGNMENT, "=", param, def)
+			}
+		}
+		params = append(params, param)
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(")")
+	return makeNode(n_PARAM_LIST, "", params...)
+}
+
+func (p *parser) parseIf() ast {
+	p.expect("if")
+	p.expect("(")
+	cond := p.parseExpression(0)
+	p.expect(")")
+	consequent := p.parseStatement()
+	alternate := emptyNode
+	if p.accept("else") {
+		alternate = p.parseStatement()
+	}
+	return makeNode(n_IF_STATEMENT, "", cond, consequent, alternate)
+}
+
+func (p *parser) parseFor() ast {
+	p.expect("for")
+	// for await iterates an async iterable, only the of form exists
+	isAwait := p.accept("await")
+	p.expect("(")
+
+	init := emptyNode
+	if p.lexeme() != ";" {
+		if p.lexeme() == "var" || p.lexeme() == "let" || p.lexeme() == "const" {
+			init = p.parseVarStatement()
+		} else {
+			init = makeNode(n_EXPRESSION_STATEMENT, "", p.parseExpression(0))
+		}
+	}
+
+	if p.lexeme() == "in" || p.lexeme() == "of" {
+		kind := p.next().lexeme
+		if isAwait {
+			kind = "await " + kind
+		}
+		right := p.parseExpression(1)
+		p.expect(")")
+		body := p.parseStatement()
+		return makeNode(n_FOR_IN_STATEMENT, kind, init, right, body)
+	}
+	if isAwait {
+		panic(parseError{message: "for await needs an of loop", tok: p.tok()})
+	}
+
+	p.expect(";")
+	test := emptyNode
+	if p.lexeme() != ";" {
+		test = p.parseExpression(0)
+	}
+	p.expect(";")
+	update := emptyNode
+	if p.lexeme() != ")" {
+		update = p.parseExpression(0)
+	}
+	p.expect(")")
+	body := p.parseStatement()
+	return makeNode(n_FOR_STATEMENT, "", init, test, update, body)
+}
+
+func (p *parser) parseWhile() ast {
+	p.expect("while")
+	p.expect("(")
+	cond := p.parseExpression(0)
+	p.expect(")")
+	body := p.parseStatement()
+	return makeNode(n_WHILE_STATEMENT, "", cond, body)
+}
+
+func (p *parser) parseDoWhile() ast {
+	p.expect("do")
+	body := p.parseStatement()
+	p.expect("while")
+	p.expect("(")
+	cond := p.parseExpression(0)
+	p.expect(")")
+	p.accept(";")
+	return makeNode(n_DO_WHILE_STATEMENT, "", cond, body)
+}
+
+func (p *parser) parseTry() ast {
+	p.expect("try")
+	block := p.parseBlock()
+	catchParam := emptyNode
+	catchBody := emptyNode
+	finallyBody := emptyNode
+	if p.accept("catch") {
+		p.expect("(")
+		catchParam = p.parseBindingTarget()
+		p.expect(")")
+		catchBody = p.parseBlock()
+	}
+	if p.accept("finally") {
+		finallyBody = p.parseBlock()
+	}
+	return makeNode(n_TRY_STATEMENT, "", block, catchParam, catchBody, finallyBody)
+}
+
+func (p *parser) parseSwitch() ast {
+	p.expect("switch")
+	p.expect("(")
+	disc := p.parseExpression(0)
+	p.expect(")")
+	p.expect("{")
+	cases := []ast{disc}
+	for p.lexeme() != "}" && p.tok().tType != tEOF {
+		test := emptyNode
+		if p.accept("case") {
+			test = p.parseExpression(1)
+		} else {
+			p.expect("default")
+		}
+		p.expect(":")
+		statements := []ast{test}
+		for p.lexeme() != "case" && p.lexeme() != "default" &&
+			p.lexeme() != "}" && p.tok().tType != tEOF {
+			statements = append(statements, p.parseStatement())
+		}
+		cases = append(cases, makeNode(n_SWITCH_CASE, "", statements...))
+	}
+	p.expect("}")
+	return makeNode(n_SWITCH_STATEMENT, "", cases...)
+}
+
+func (p *parser) parseImport() ast {
+	p.expect("import")
+
+	// a type-only import has no runtime effect and drops entirely.
+	// 'import type from' still imports a default named type
+	if p.ts && p.lexeme() == "type" &&
+		p.tokens[p.pos+1].lexeme != "from" && p.tokens[p.pos+1].lexeme != "," {
+		for p.tok().tType != tSTRING && p.tok().tType != tEOF {
+			p.next()
+		}
+		p.next()
+		p.accept(";")
+		return makeNode(n_EMPTY_STATEMENT, "")
+	}
+
+	specifiers := []ast{}
+	if p.tok().tType == tSTRING {
+		path := stringLexemeValue(p.next().lexeme)
+		p.accept(";")
+		return makeNode(n_IMPORT_STATEMENT, path, specifiers...)
+	}
+
+	for {
+		if p.tok().tType == tNAME && p.lexeme() != "from" {
+			name := makeNode(n_IDENTIFIER, p.next().lexeme)
+			specifiers = append(specifiers, makeNode(n_IMPORT_DEFAULT, "", name))
+		} else if p.accept("*") {
+			p.expect("as")
+			name := makeNode(n_IDENTIFIER, p.expectType(tNAME, "identifier").lexeme)
+			specifiers = append(specifiers, makeNode(n_IMPORT_STAR, "", name))
+		} else if p.accept("{") {
+			for p.lexeme() != "}" && p.tok().tType != tEOF {
+				imported := makeNode(n_IDENTIFIER, p.expectType(tNAME, "identifier").lexeme)
+				local := imported
+				if p.accept("as") {
+					local = makeNode(n_IDENTIFIER, p.expectType(tNAME, "identifier").lexeme)
+				}
+				specifiers = append(specifiers, makeNode(n_IMPORT_NAMED, "", imported, local))
+				if !p.accept(",") {
+					break
+				}
+			}
+			p.expect("}")
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	p.expect("from")
+	path := stringLexemeValue(p.expectType(tSTRING, "module path").lexeme)
+	p.accept(";")
+	return makeNode(n_IMPORT_STATEMENT, path, specifiers...)
+}
+
+func (p *parser) parseExport() ast {
+	p.expect("export")
+
+	// exported types disappear along with the types themselves
+	if p.ts && p.lexeme() == "interface" && p.tokens[p.pos+1].tType == tNAME {
+		return p.parseInterfaceDeclaration()
+	}
+	if p.isTypeAliasAhead() {
+		return p.parseTypeAlias()
+	}
+	if p.ts && p.lexeme() == "type" && p.tokens[p.pos+1].lexeme == "{" {
+		for p.lexeme() != "}" && p.tok().tType != tEOF {
+			p.next()
+		}
+		p.next()
+		if p.accept("from") {
+			p.expectType(tSTRING, "module path")
+		}
+		p.accept(";")
+		return makeNode(n_EMPTY_STATEMENT, "")
+	}
+
+	if p.accept("default") {
+		var value ast
+		if p.lexeme() == "function" {
+			value = p.parseFunctionExpressionOrDeclaration()
+		} else if p.lexeme() == "class" {
+			value = p.parseClass(n_CLASS_DECLARATION)
+			if value.children[0].t == n_EMPTY {
+				value.t = n_CLASS_EXPRESSION
+			}
+		} else {
+			value = p.parseExpression(2)
+			p.accept(";")
+		}
+		return makeNode(n_EXPORT_STATEMENT, "",
+			makeNode(n_EXPORT_DEFAULT, "", value))
+	}
+
+	if p.accept("*") {
+		p.expect("from")
+		path := stringLexemeValue(p.expectType(tSTRING, "module path").lexeme)
+		p.accept(";")
+		return makeNode(n_EXPORT_STATEMENT, path, makeNode(n_EXPORT_ALL, ""))
+	}
+
+	if p.accept("{") {
+		specifiers := []ast{}
+		for p.lexeme() != "}" && p.tok().tType != tEOF {
+			local := makeNode(n_IDENTIFIER, p.expectType(tNAME, "identifier").lexeme)
+			exported := local
+			if p.accept("as") {
+				exported = makeNode(n_IDENTIFIER, p.expectType(tNAME, "identifier").lexeme)
+			}
+			specifiers = append(specifiers, makeNode(n_EXPORT_NAMED, "", exported, local))
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.expect("}")
+		path := ""
+		if p.accept("from") {
+			path = stringLexemeValue(p.expectType(tSTRING, "module path").lexeme)
+		}
+		p.accept(";")
+		return makeNode(n_EXPORT_STATEMENT, path, specifiers...)
+	}
+
+	if p.lexeme() == "var" || p.lexeme() == "let" || p.lexeme() == "const" ||
+		p.lexeme() == "function" || p.lexeme() == "class" {
+		var decl ast
+		if p.lexeme() == "function" {
+			decl = p.parseFunctionDeclaration()
+		} else if p.lexeme() == "class" {
+			decl = p.parseClass(n_CLASS_DECLARATION)
+		} else {
+			decl = p.parseVarStatement()
+			p.accept(";")
+		}
+		return makeNode(n_EXPORT_STATEMENT, "",
+			makeNode(n_EXPORT_DECLARATION, "", decl))
+	}
+
+	panic(parseError{message: "unexpected token in export statement", tok: p.tok()})
+}
+
+func (p *parser) parseFunctionExpressionOrDeclaration() ast {
+	p.expect("function")
+	generator := ""
+	if p.accept("*") {
+		generator = "*"
+	}
+	name := emptyNode
+	if p.tok().tType == tNAME && p.lexeme() != "(" {
+		name = makeNode(n_IDENTIFIER, p.next().lexeme)
+	}
+	if p.ts && p.lexeme() == "<" {
+		p.skipTypeArgs()
+	}
+	params := p.parseParams()
+	p.skipTypeAnnotation()
+	body := p.parseBlock()
+	if name.t == n_EMPTY {
+		return makeNode(n_FUNCTION_EXPRESSION, generator, name, params, body)
+	}
+	return makeNode(n_FUNCTION_DECLARATION, generator, name, params, body)
+}
+
+// stringLexemeValue strips the surrounding quotes from a string
+// token lexeme
+func stringLexemeValue(lexeme string) string {
+	if len(lexeme) >= 2 {
+		return lexeme[1 : len(lexeme)-1]
+	}
+	return lexeme
+}
+
+// parseExpression parses with precedence climbing.
+// minPrec 0 allows sequences, 1 allows assignments,
+// 2 starts at conditional
+// isExpressionEnd tells whether the current token can only close
+// an expression, used for operators with an optional operand
+func (p *parser) isExpressionEnd() bool {
+	switch p.lexeme() {
+	case ";", ")", "]", "}", ",", ":":
+		return true
+	}
+	return p.tok().tType == tEOF
+}
+
+func (p *parser) parseExpression(minPrec int) ast {
+	if p.lexeme() == "yield" && minPrec <= 2 {
+		p.next()
+		op := "yield"
+		if p.accept("*") {
+			op = "yield*"
+		}
+		if p.isExpressionEnd() {
+			return makeNode(n_PREFIX_UNARY, op, emptyNode)
+		}
+		return makeNode(n_PREFIX_UNARY, op, p.parseExpression(2))
+	}
+
+	left := p.parseUnary()
+
+	for {
+		lexeme := p.lexeme()
+		if p.ts && lexeme == "as" {
+			// an 'as' cast has no runtime effect
+			p.next()
+			p.skipType()
+			continue
+		}
+		if p.tok().tType != tPUNCTUATOR && lexeme != "in" && lexeme != "instanceof" {
+			break
+		}
+
+		if assignmentOperators[lexeme] && minPrec <= 2 {
+			p.next()
+			right := p.parseExpression(2)
+			left = makeNode(n_ASSIGNMENT, lexeme, left, right)
+			continue
+		}
+
+		if lexeme == "?" && minPrec <= 3 {
+			p.next()
+			consequent := p.parseExpression(2)
+			p.expect(":")
+			alternate := p.parseExpression(2)
+			left = makeNode(n_CONDITIONAL, "", left, consequent, alternate)
+			continue
+		}
+
+		if lexeme == "," && minPrec == 0 {
+			p.next()
+			right := p.parseExpression(1)
+			left = makeNode(n_SEQUENCE, "", left, right)
+			continue
+		}
+
+		prec, isBinary := binaryPrecedence[lexeme]
+		if !isBinary || prec < minPrec {
+			break
+		}
+		p.next()
+		right := p.parseBinary(nextPrecedence(lexeme, prec))
+		left = makeNode(n_BINARY, lexeme, left, right)
+	}
+
+	return left
+}
+
+func nextPrecedence(op string, prec int) int {
+	if rightAssociative[op] {
+		return prec
+	}
+	return prec + 1
+}
+
+func (p *parser) parseBinary(minPrec int) ast {
+	left := p.parseUnary()
+	for {
+		prec, isBinary := binaryPrecedence[p.lexeme()]
+		if !isBinary || prec < minPrec {
+			break
+		}
+		op := p.next().lexeme
+		right := p.parseBinary(nextPrecedence(op, prec))
+		left = makeNode(n_BINARY, op, left, right)
+	}
+	return left
+}
+
+func (p *parser) parseUnary() ast {
+	lexeme := p.lexeme()
+	switch lexeme {
+	case "!", "~", "+", "-", "typeof", "void", "delete", "await":
+		p.next()
+		return makeNode(n_PREFIX_UNARY, lexeme, p.parseUnary())
+	case "++", "--":
+		p.next()
+		return makeNode(n_PREFIX_UNARY, lexeme, p.parseUnary())
+	case "new":
+		p.next()
+		// new.target is a meta property, not a construction
+		if p.accept(".") {
+			target := p.expectType(tNAME, "meta property name")
+			if target.lexeme != "target" {
+				panic(parseError{message: "unknown meta property new." + target.lexeme,
+					tok: target})
+			}
+			meta := makeNode(n_META_PROPERTY, "new.target")
+			return p.parsePostfix(p.parseCallOrMember(meta))
+		}
+		callee := p.parseMemberOnly(p.parsePrimary())
+		args := []ast{callee}
+		if p.accept("(") {
+			args = append(args, p.parseArgs()...)
+		}
+		return p.parsePostfix(p.parseCallOrMember(makeNode(n_NEW, "", args...)))
+	}
+	return p.parsePostfix(p.parseCallOrMember(p.parsePrimary()))
+}
+
+func (p *parser) parsePostfix(operand ast) ast {
+	if p.lexeme() == "++" || p.lexeme() == "--" {
+		op := p.next().lexeme
+		return makeNode(n_POSTFIX_UNARY, op, operand)
+	}
+	return operand
+}
+
+func (p *parser) parseArgs() []ast {
+	args := []ast{}
+	for p.lexeme() != ")" && p.tok().tType != tEOF {
+		if p.accept("...") {
+			args = append(args, makeNode(n_SPREAD, "", p.parseExpression(2)))
+		} else {
+			args = append(args, p.parseExpression(2))
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(")")
+	return args
+}
+
+func (p *parser) parseCallOrMember(left ast) ast {
+	for {
+		switch {
+		case p.accept("."):
+			prop := makeNode(n_IDENTIFIER, p.expectType(tNAME, "property name").lexeme)
+			left = makeNode(n_MEMBER, ".", left, prop)
+		case p.accept("?."):
+			switch {
+			case p.accept("["):
+				prop := p.parseExpression(0)
+				p.expect("]")
+				left = makeNode(n_MEMBER, "?.[", left, prop)
+			case p.accept("("):
+				args := p.parseArgs()
+				left = makeNode(n_CALL, "?.", append([]ast{left}, args...)...)
+			default:
+				prop := makeNode(n_IDENTIFIER, p.expectType(tNAME, "property name").lexeme)
+				left = makeNode(n_MEMBER, "?.", left, prop)
+			}
+		case p.accept("["):
+			prop := p.parseExpression(0)
+			p.expect("]")
+			left = makeNode(n_MEMBER, "[", left, prop)
+		case p.accept("("):
+			args := p.parseArgs()
+			left = makeNode(n_CALL, "", append([]ast{left}, args...)...)
+		case p.tok().tType == tTEMPLATE:
+			template := parseTemplateLiteral(p.next())
+			left = makeNode(n_TAGGED_TEMPLATE, "", left, template)
+		case p.ts && p.lexeme() == "<" && p.isTypeArgsAhead():
+			p.skipTypeArgs()
+		case p.ts && p.accept("!"):
+			// a non-null assertion, no runtime meaning
+		default:
+			return left
+		}
+	}
+}
+
+// parseTemplateLiteral splits a raw template token into string
+// chunks and interpolated expressions, which are parsed with a
+// fresh sub-parser
+func parseTemplateLiteral(t token) ast {
+	raw := []rune(t.lexeme)
+	// strip the surrounding backticks
+	raw = raw[1 : len(raw)-1]
+
+	children := []ast{}
+	chunk := ""
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' && i+1 < len(raw) {
+			chunk += string(raw[i]) + string(raw[i+1])
+			i++
+			continue
+		}
+		if raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{' {
+			children = append(children, makeNode(n_TEMPLATE_STRING, chunk))
+			chunk = ""
+
+			depth := 1
+			j := i + 2
+			for j < len(raw) && depth > 0 {
+				switch raw[j] {
+				case '\\':
+					j++
+				case '\'', '"':
+					quote := raw[j]
+					j++
+					for j < len(raw) && raw[j] != quote {
+						if raw[j] == '\\' {
+							j++
+						}
+						j++
+					}
+				case '{':
+					depth++
+				case '}':
+					depth--
+				}
+				j++
+			}
+			exprSrc := string(raw[i+2 : j-1])
+			sub := parser{tokens: lex([]byte(exprSrc))}
+			children = append(children, sub.parseExpression(0))
+			i = j - 1
+			continue
+		}
+		chunk += string(raw[i])
+	}
+	children = append(children, makeNode(n_TEMPLATE_STRING, chunk))
+	return makeNode(n_TEMPLATE_LITERAL, "", children...)
+}
+
+func (p *parser) parseMemberOnly(left ast) ast {
+	for {
+		switch {
+		case p.accept("."):
+			prop := makeNode(n_IDENTIFIER, p.expectType(tNAME, "property name").lexeme)
+			left = makeNode(n_MEMBER, ".", left, prop)
+		case p.accept("["):
+			prop := p.parseExpression(0)
+			p.expect("]")
+			left = makeNode(n_MEMBER, "[", left, prop)
+		default:
+			return left
+		}
+	}
+}
+
+// isArrowAhead looks past a '(' at the given position to check
+// whether the matching ')' is followed by '=>'
+func (p *parser) isArrowAhead(start int) bool {
+	depth := 0
+	i := start
+	for i < len(p.tokens) {
+		switch p.tokens[i].lexeme {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				if i+1 >= len(p.tokens) {
+					return false
+				}
+				next := p.tokens[i+1].lexeme
+				// in typescript a return annotation can sit between
+				// the params and the arrow
+				return next == "=>" || (p.ts && next == ":")
+			}
+		}
+		if p.tokens[i].tType == tEOF {
+			return false
+		}
+		i++
+	}
+	return false
+}
+
+func (p *parser) parseArrowBody() ast {
+	if p.lexeme() == "{" {
+		return p.parseBlock()
+	}
+	return p.parseExpression(2)
+}
+
+func (p *parser) parsePrimary() ast {
+	t := p.tok()
+
+	switch t.tType {
+	case tNUMBER:
+		p.next()
+		return makeNode(n_NUMBER_LITERAL, t.lexeme)
+	case tSTRING:
+		p.next()
+		return makeNode(n_STRING_LITERAL, t.lexeme)
+	case tREGEX:
+		p.next()
+		return makeNode(n_REGEX_LITERAL, t.lexeme)
+	case tTEMPLATE:
+		p.next()
+		return parseTemplateLiteral(t)
+	case tJSX:
+		p.next()
+		return parseJSXToken(t)
+	}
+
+	switch t.lexeme {
+	case "(":
+		if p.isArrowAhead(p.pos) {
+			params := p.parseParams()
+			p.skipArrowReturnType()
+			p.expect("=>")
+			body := p.parseArrowBody()
+			return makeNode(n_ARROW_FUNCTION, "", params, body)
+		}
+		p.next()
+		expr := p.parseExpression(0)
+		p.expect(")")
+		return makeNode(n_PAREN, "", expr)
+
+	case "{":
+		return p.parseObjectLiteral()
+
+	case "[":
+		return p.parseArrayLiteral()
+
+	case "function":
+		return p.parseFunctionExpression()
+
+	case "class":
+		return p.parseClass(n_CLASS_EXPRESSION)
+
+	case "import":
+		// dynamic import(), the keyword only acts like a callee
+		p.next()
+		// import.meta is a meta property whose value is left for
+		// the runtime to define
+		if p.accept(".") {
+			meta := p.expectType(tNAME, "meta property name")
+			if meta.lexeme != "meta" {
+				panic(parseError{message: "unknown meta property import." + meta.lexeme,
+					tok: meta})
+			}
+			return makeNode(n_META_PROPERTY, "import.meta")
+		}
+		p.expect("(")
+		arg := p.parseExpression(2)
+		p.expect(")")
+		return makeNode(n_IMPORT_CALL, "", arg)
+
+	case "async":
+		if p.tokens[p.pos+1].lexeme == "function" {
+			p.next()
+			fn := p.parseFunctionExpression()
+			fn.value = "async" + fn.value
+			return fn
+		}
+		if p.tokens[p.pos+1].tType == tNAME && p.tokens[p.pos+2].lexeme == "=>" {
+			p.next()
+			name := p.next().lexeme
+			p.expect("=>")
+			params := makeNode(n_PARAM_LIST, "", makeNode(n_IDENTIFIER, name))
+			body := p.parseArrowBody()
+			return makeNode(n_ARROW_FUNCTION, "async", params, body)
+		}
+		if p.tokens[p.pos+1].lexeme == "(" && p.isArrowAhead(p.pos+1) {
+			p.next()
+			params := p.parseParams()
+			p.skipArrowReturnType()
+			p.expect("=>")
+			body := p.parseArrowBody()
+			return makeNode(n_ARROW_FUNCTION, "async", params, body)
+		}
+	}
+
+	if t.tType == tNAME {
+		p.next()
+		if p.lexeme() == "=>" {
+			p.next()
+			params := makeNode(n_PARAM_LIST, "", makeNode(n_IDENTIFIER, t.lexeme))
+			body := p.parseArrowBody()
+			return makeNode(n_ARROW_FUNCTION, "", params, body)
+		}
+		// the literal keywords get their own node types here, in
+		// value position, so property names and binding names spelled
+		// the same stay plain identifiers
+		switch t.lexeme {
+		case "true", "false":
+			return makeNode(n_BOOL_LITERAL, t.lexeme)
+		case "undefined":
+			return makeNode(n_UNDEFINED, t.lexeme)
+		}
+		return makeNode(n_IDENTIFIER, t.lexeme)
+	}
+
+	panic(parseError{message: "unexpected token", tok: t})
+}
+
+// parseDecorated consumes a run of @expr decorators and wraps the
+// decorated node in one n_DECORATOR per decorator, the innermost
+// holding the decorator written closest to the target. Decorators
+// are emitted back as written, running them is left to the
+// frameworks that define them
+func (p *parser) parseDecorated(parseTarget func() ast) ast {
+	p.expect("@")
+	expr := p.parseCallOrMember(p.parsePrimary())
+
+	var target ast
+	if p.lexeme() == "@" {
+		target = p.parseDecorated(parseTarget)
+	} else {
+		target = parseTarget()
+	}
+	return makeNode(n_DECORATOR, "", expr, target)
+}
+
+// parseClass parses a class declaration or expression: the name,
+// the optional extends clause and the member methods
+func (p *parser) parseClass(t astType) ast {
+	p.expect("class")
+
+	name := emptyNode
+	if p.tok().tType == tNAME && p.lexeme() != "extends" && p.lexeme() != "{" {
+		name = makeNode(n_IDENTIFIER, p.next().lexeme)
+	}
+	if p.ts && p.lexeme() == "<" {
+		p.skipTypeArgs()
+	}
+
+	super := emptyNode
+	if p.accept("extends") {
+		super = p.parseMemberOnly(p.parsePrimary())
+		if p.ts && p.lexeme() == "<" {
+			p.skipTypeArgs()
+		}
+	}
+	if p.ts && p.accept("implements") {
+		for p.lexeme() != "{" && p.tok().tType != tEOF {
+			p.next()
+		}
+	}
+
+	children := []ast{name, super}
+	p.expect("{")
+	for p.lexeme() != "}" && p.tok().tType != tEOF {
+		if p.accept(";") {
+			continue
+		}
+		children = append(children, p.parseClassMember())
+	}
+	p.expect("}")
+
+	return makeNode(t, "", children...)
+}
+
+func (p *parser) parseClassMember() ast {
+	if p.lexeme() == "@" {
+		return p.parseDecorated(p.parseClassMember)
+	}
+
+	// static/async/get/set are only modifiers when they are not
+	// themselves the member name
+	isModifier := func() bool {
+		next := p.tokens[p.pos+1].lexeme
+		return next != "(" && next != "=" && next != ";" && next != "}"
+	}
+
+	modifiers := []string{}
+	if p.lexeme() == "static" && isModifier() {
+		p.next()
+		modifiers = append(modifiers, "static")
+	}
+	if p.lexeme() == "async" && isModifier() {
+		p.next()
+		modifiers = append(modifiers, "async")
+	}
+	if p.accept("*") {
+		modifiers = append(modifiers, "*")
+	}
+	if (p.lexeme() == "get" || p.lexeme() == "set") && isModifier() {
+		modifiers = append(modifiers, p.next().lexeme)
+	}
+
+	var key ast
+	switch {
+	case p.lexeme() == "[":
+		p.next()
+		key = makeNode(n_COMPUTED_KEY, "", p.parseExpression(2))
+		p.expect("]")
+	case p.tok().tType == tNAME:
+		key = makeNode(n_IDENTIFIER, p.next().lexeme)
+	case p.tok().tType == tSTRING:
+		key = makeNode(n_STRING_LITERAL, p.next().lexeme)
+	case p.tok().tType == tNUMBER:
+		key = makeNode(n_NUMBER_LITERAL, p.next().lexeme)
+	default:
+		panic(parseError{message: "unexpected token in class body", tok: p.tok()})
+	}
+
+	if p.ts && p.lexeme() == "<" {
+		p.skipTypeArgs()
+	}
+
+	// no parameter list makes this a field declaration with an
+	// optional initializer. Browsers run both public and private
+	// fields natively, so they pass through untransformed
+	if p.lexeme() != "(" {
+		if p.ts {
+			p.accept("?")
+		}
+		p.skipTypeAnnotation()
+		value := emptyNode
+		if p.accept("=") {
+			value = p.parseExpression(2)
+		}
+		p.accept(";")
+		return makeNode(n_CLASS_FIELD, strings.Join(modifiers, " "), key, value)
+	}
+
+	params := p.parseParams()
+	p.skipTypeAnnotation()
+	body := p.parseBlock()
+	return makeNode(n_CLASS_METHOD, strings.Join(modifiers, " "), key, params, body)
+}
+
+func (p *parser) parseFunctionExpression() ast {
+	p.expect("function")
+	generator := ""
+	if p.accept("*") {
+		generator = "*"
+	}
+	name := emptyNode
+	if p.tok().tType == tNAME {
+		name = makeNode(n_IDENTIFIER, p.next().lexeme)
+	}
+	if p.ts && p.lexeme() == "<" {
+		p.skipTypeArgs()
+	}
+	params := p.parseParams()
+	p.skipTypeAnnotation()
+	body := p.parseBlock()
+	return makeNode(n_FUNCTION_EXPRESSION, generator, name, params, body)
+}
+
+func (p *parser) parseObjectLiteral() ast {
+	p.expect("{")
+	props := []ast{}
+	for p.lexeme() != "}" && p.tok().tType != tEOF {
+		props = append(props, p.parseObjectMember())
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("}")
+	return makeNode(n_OBJECT_LITERAL, "", props...)
+}
+
+func (p *parser) parseObjectMember() ast {
+	// async/get/set are only modifiers when they are not themselves
+	// the key, i.e. not directly followed by (, :, , or }
+	isModifier := func() bool {
+		next := p.tokens[p.pos+1].lexeme
+		return next != "(" && next != ":" && next != "," && next != "}"
+	}
+
+	if p.accept("...") {
+		return makeNode(n_SPREAD, "", p.parseExpression(2))
+	}
+
+	modifiers := []string{}
+	if p.lexeme() == "async" && isModifier() {
+		p.next()
+		modifiers = append(modifiers, "async")
+	}
+	if p.accept("*") {
+		modifiers = append(modifiers, "*")
+	}
+	if (p.lexeme() == "get" || p.lexeme() == "set") && isModifier() {
+		modifiers = append(modifiers, p.next().lexeme)
+	}
+
+	var key ast
+	switch {
+	case p.lexeme() == "[":
+		p.next()
+		key = makeNode(n_COMPUTED_KEY, "", p.parseExpression(2))
+		p.expect("]")
+	case p.tok().tType == tNAME:
+		key = makeNode(n_IDENTIFIER, p.next().lexeme)
+	case p.tok().tType == tSTRING:
+		key = makeNode(n_STRING_LITERAL, p.next().lexeme)
+	case p.tok().tType == tNUMBER:
+		key = makeNode(n_NUMBER_LITERAL, p.next().lexeme)
+	default:
+		panic(parseError{message: "unexpected token in object literal", tok: p.tok()})
+	}
+
+	if p.lexeme() == "(" {
+		params := p.parseParams()
+		body := p.parseBlock()
+		return makeNode(n_OBJECT_METHOD, strings.Join(modifiers, " "), key, params, body)
+	}
+	if p.accept(":") {
+		return makeNode(n_OBJECT_PROP, "", key, p.parseExpression(2))
+	}
+	if key.t != n_IDENTIFIER || len(modifiers) > 0 {
+		panic(parseError{message: "unexpected token in object literal", tok: p.tok()})
+	}
+	// shorthand property
+	return makeNode(n_OBJECT_PROP, "", key, key)
+}
+
+func (p *parser) parseArrayLiteral() ast {
+	p.expect("[")
+	elems := []ast{}
+	for p.lexeme() != "]" && p.tok().tType != tEOF {
+		if p.lexeme() == "," {
+			elems = append(elems, emptyNode)
+			p.next()
+			continue
+		}
+		if p.accept("...") {
+			elems = append(elems, makeNode(n_SPREAD, "", p.parseExpression(2)))
+		} else {
+			elems = append(elems, p.parseExpression(2))
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("]")
+	return makeNode(n_ARRAY_LITERAL, "", elems...)
+}
@@ -0,0 +1,333 @@
+package jsLoader
+
+import (
+	"strings"
+	"testing"
+)
+
+// expectPrinted parses src and checks that printing the ast gives
+// back the expected code
+func expectPrinted(t *testing.T, src, expected string) {
+	t.Helper()
+	program, err := parse(lex([]byte(src)))
+	if err != nil {
+		t.Fatalf("parse error in %q: %v", src, err)
+	}
+	printed := printAst(program)
+	if printed != expected {
+		t.Errorf("print mismatch:\nsource:   %s\nexpected: %s\ngot:      %s",
+			src, expected, printed)
+	}
+}
+
+func TestAsyncFunctions(t *testing.T) {
+	expectPrinted(t,
+		"async function f(a) { return a; }",
+		"async function f(a) {\n  return a;\n}")
+	expectPrinted(t,
+		"var f = async function() { return 1; };",
+		"var f = async function() {\n  return 1;\n};")
+}
+
+func TestAwaitExpression(t *testing.T) {
+	expectPrinted(t,
+		"async function f() { var x = await g(); }",
+		"async function f() {\n  var x = await g();\n}")
+}
+
+func TestGeneratorFunctions(t *testing.T) {
+	expectPrinted(t,
+		"function* g() { yield 1; yield; }",
+		"function* g() {\n  yield 1;\n  yield;\n}")
+	expectPrinted(t,
+		"var g = function*() { yield* other(); };",
+		"var g = function*() {\n  yield* other();\n};")
+	expectPrinted(t,
+		"async function* g() { yield await f(); }",
+		"async function* g() {\n  yield await f();\n}")
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	expectPrinted(t, "var s = `hello`;", "var s = `hello`;")
+	expectPrinted(t, "var s = `a ${x + 1} b ${y} c`;", "var s = `a ${x + 1} b ${y} c`;")
+	expectPrinted(t, "var s = `outer ${`inner ${x}`} end`;",
+		"var s = `outer ${`inner ${x}`} end`;")
+	expectPrinted(t, "var s = tag`a ${x} b`;", "var s = tag`a ${x} b`;")
+}
+
+func TestMultiLineTemplates(t *testing.T) {
+	// raw newlines inside templates have to survive untouched
+	expectPrinted(t, "var s = `line1\nline2`;", "var s = `line1\nline2`;")
+	expectPrinted(t, "var s = `a\u2028b`;", "var s = `a\u2028b`;")
+
+	// and tokens after a multi-line template get correct lines
+	tokens := lex([]byte("`a\nb`;\nvar x;"))
+	for _, tok := range tokens {
+		if tok.lexeme == "var" && tok.line != 2 {
+			t.Errorf("expected 'var' on line 2, got %v", tok.line)
+		}
+	}
+}
+
+func TestArraySpread(t *testing.T) {
+	expectPrinted(t, "var a = [1, ...rest, 2];", "var a = [1, ...rest, 2];")
+	expectPrinted(t, "f(a, ...args);", "f(a, ...args);")
+	expectPrinted(t, "var b = [...a, ...[1, 2]];", "var b = [...a, ...[1, 2]];")
+}
+
+func TestExponentiation(t *testing.T) {
+	expectPrinted(t, "var x = a ** b;", "var x = a ** b;")
+	expectPrinted(t, "var x = 2 * a ** 3;", "var x = 2 * a ** 3;")
+	expectPrinted(t, "x **= 2;", "x **= 2;")
+
+	// ** groups from the right
+	program, err := parse(lex([]byte("var x = a ** b ** c;")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expr := program.children[0].children[0].children[1]
+	if expr.t != n_BINARY || expr.children[0].t != n_IDENTIFIER ||
+		expr.children[1].t != n_BINARY {
+		t.Errorf("expected a ** (b ** c) grouping, got %v", printAst(expr))
+	}
+}
+
+func TestLogicalAssignment(t *testing.T) {
+	expectPrinted(t, "x ||= f();", "x ||= f();")
+	expectPrinted(t, "x &&= y;", "x &&= y;")
+	expectPrinted(t, "x ??= fallback;", "x ??= fallback;")
+	expectPrinted(t, "var x = a ?? b;", "var x = a ?? b;")
+
+	// logical assignment chains from the right like plain =
+	program, err := parse(lex([]byte("a ??= b ??= c;")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expr := program.children[0].children[0]
+	if expr.t != n_ASSIGNMENT || expr.children[1].t != n_ASSIGNMENT {
+		t.Errorf("expected a ??= (b ??= c) grouping, got %v", printAst(expr))
+	}
+}
+
+func TestOptionalChaining(t *testing.T) {
+	expectPrinted(t, "var x = a?.b;", "var x = a?.b;")
+	expectPrinted(t, "var x = a?.b?.c.d;", "var x = a?.b?.c.d;")
+	expectPrinted(t, "var x = a?.[key];", "var x = a?.[key];")
+	expectPrinted(t, "var x = f?.(1, 2);", "var x = f?.(1, 2);")
+}
+
+func TestArrayDestructuring(t *testing.T) {
+	expectPrinted(t, "var [a, b] = pair;", "var [a, b] = pair;")
+	expectPrinted(t, "var [a, , c] = list;", "var [a, , c] = list;")
+	expectPrinted(t, "var [a = 1, [b, c]] = nested;", "var [a = 1, [b, c]] = nested;")
+	expectPrinted(t, "var [head, ...tail] = list;", "var [head, ...tail] = list;")
+}
+
+func TestObjectDestructuring(t *testing.T) {
+	expectPrinted(t, "var {a, b: c} = obj;", "var {a: a, b: c} = obj;")
+	expectPrinted(t, "var {a = 1, b: {c}} = obj;", "var {a: a = 1, b: {c: c}} = obj;")
+	expectPrinted(t, "var {a, ...rest} = obj;", "var {a: a, ...rest} = obj;")
+	expectPrinted(t,
+		"function f({a, ...rest}) { return rest; }",
+		"function f({a: a, ...rest}) {\n  return rest;\n}")
+}
+
+func TestObjectSpread(t *testing.T) {
+	expectPrinted(t, "var o = {...base, x: 1};", "var o = {...base, x: 1};")
+	expectPrinted(t, "var o = {...f(), ...g()};", "var o = {...f(), ...g()};")
+}
+
+func TestDestructuredParameters(t *testing.T) {
+	expectPrinted(t,
+		"function f([a, b], ...rest) { return a; }",
+		"function f([a, b], ...rest) {\n  return a;\n}")
+	expectPrinted(t, "var f = ([x, y]) => x + y;", "var f = ([x, y]) => x + y;")
+}
+
+func TestMultipleParseErrors(t *testing.T) {
+	src := "var = 1;\nvar ok = 2;\nvar = 3;"
+	_, err := parse(lex([]byte(src)))
+	if err == nil {
+		t.Fatal("expected parse errors")
+	}
+	errs, ok := err.(parseErrors)
+	if !ok {
+		t.Fatalf("expected parseErrors, got %T", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("expected both errors collected, got %v", errs)
+	}
+	if errs[0].tok.line != 0 || errs[1].tok.line != 2 {
+		t.Errorf("expected errors on lines 1 and 3, got %v", errs)
+	}
+	// the combined message lists every position
+	if !strings.Contains(err.Error(), "at 1:") || !strings.Contains(err.Error(), "at 3:") {
+		t.Errorf("expected both positions in the message, got %v", err)
+	}
+}
+
+func TestParseErrorCodeFrame(t *testing.T) {
+	src := "var a = 1;\nvar = 2;\nvar b = 3;"
+	_, err := LoadFile([]byte(src), "src/bad.js")
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	msg := err.Error()
+
+	for _, part := range []string{"  1 | var a = 1;", "> 2 | var = 2;", "  3 | var b = 3;"} {
+		if !strings.Contains(msg, part) {
+			t.Errorf("expected %q in the code frame, got:\n%s", part, msg)
+		}
+	}
+
+	// the caret lines up with the offending token
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "> 2 |") {
+			caret := strings.Index(lines[i+1], "^")
+			if caret != strings.Index(line, "=") {
+				t.Errorf("caret misaligned:\n%s\n%s", line, lines[i+1])
+			}
+		}
+	}
+}
+
+func TestErrorRecoverySkipsBlocks(t *testing.T) {
+	// the bad statement spans a block, recovery resumes after it
+	src := "if (a { b(); }\nvar ok = 1;"
+	_, err := parse(lex([]byte(src)))
+	errs, ok := err.(parseErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected exactly one error, got %v", err)
+	}
+	if errs[0].tok.line != 0 {
+		t.Errorf("expected the error on line 1, got %v", errs)
+	}
+}
+
+func TestClassDeclarations(t *testing.T) {
+	expectPrinted(t,
+		"class A { constructor(x) { this.x = x; } getX() { return this.x; } }",
+		"class A {\n  constructor(x) {\n    this.x = x;\n  }\n  getX() {\n    return this.x;\n  }\n}")
+	expectPrinted(t,
+		"class B extends A { static create() { return new B(); } }",
+		"class B extends A {\n  static create() {\n    return new B();\n  }\n}")
+	expectPrinted(t,
+		"class C { get x() { return 1; } set x(v) {} static async *gen() {} }",
+		"class C {\n  get x() {\n    return 1;\n  }\n  set x(v) {\n  }\n  static async *gen() {\n  }\n}")
+}
+
+func TestClassExpressions(t *testing.T) {
+	expectPrinted(t, "var A = class { m() {} };",
+		"var A = class {\n  m() {\n  }\n};")
+	expectPrinted(t, "var B = class Named extends A {};",
+		"var B = class Named extends A {\n};")
+}
+
+func TestAsyncArrowFunctions(t *testing.T) {
+	expectPrinted(t, "var f = async x => x + 1;", "var f = async (x) => x + 1;")
+	expectPrinted(t, "var f = async (a, b) => a + b;", "var f = async (a, b) => a + b;")
+	// 'async' on its own still works as a plain identifier
+	expectPrinted(t, "var async = 1;", "var async = 1;")
+	expectPrinted(t, "async(1);", "async(1);")
+}
+
+func TestKeywordPropertyNames(t *testing.T) {
+	// reserved words are fine as property names and object keys
+	expectPrinted(t, "promise.catch(x);", "promise.catch(x);")
+	expectPrinted(t, "obj.class = arr.delete;", "obj.class = arr.delete;")
+	expectPrinted(t, "a?.catch(x);", "a?.catch(x);")
+	expectPrinted(t, "var o = { class: 1, delete: 2 };",
+		"var o = {class: 1, delete: 2};")
+
+	// a / after a keyword property is division, not a regex start
+	expectPrinted(t, "var x = a.in / b;", "var x = a.in / b;")
+}
+
+func TestTrailingCommas(t *testing.T) {
+	// a trailing comma in parameter and argument lists is dropped
+	// instead of emitted dangling
+	expectPrinted(t, "f(a, b,);", "f(a, b);")
+	expectPrinted(t, "function g(a, b,) {}", "function g(a, b) {\n}")
+	expectPrinted(t, "var h = (a, b,) => a;", "var h = (a, b) => a;")
+	expectPrinted(t, "new X(a,);", "new X(a);")
+	expectPrinted(t, "f(...rest,);", "f(...rest);")
+}
+
+func TestPrivateClassMembers(t *testing.T) {
+	// private names parse and print verbatim, browsers run them
+	// natively so no transform touches them
+	expectPrinted(t, "class A { #count = 0; #increment() { this.#count++; } }",
+		"class A {\n  #count = 0;\n  #increment() {\n    this.#count++;\n  }\n}")
+	expectPrinted(t, "class B { static #cache; get #value() { return 1; } }",
+		"class B {\n  static #cache;\n  get #value() {\n    return 1;\n  }\n}")
+	expectPrinted(t, "var x = a.#b;", "var x = a.#b;")
+}
+
+func TestClassFields(t *testing.T) {
+	expectPrinted(t, "class A { state = {}; static defaultProps = {}; }",
+		"class A {\n  state = {};\n  static defaultProps = {};\n}")
+	// no initializer and computed names
+	expectPrinted(t, "class B { x; [key] = v; static [kind]() {} }",
+		"class B {\n  x;\n  [key] = v;\n  static [kind]() {\n  }\n}")
+	// a field named like a modifier is still a field
+	expectPrinted(t, "class C { static = 1; get = 2; }",
+		"class C {\n  static = 1;\n  get = 2;\n}")
+}
+
+func TestDecorators(t *testing.T) {
+	// decorators parse and print back in place, they are left for
+	// the framework that defines them to run
+	expectPrinted(t, "@observer class A {}", "@observer\nclass A {\n}")
+	expectPrinted(t, "@inject('store') @observer class B {}",
+		"@inject('store')\n@observer\nclass B {\n}")
+	expectPrinted(t, "class C { @observable count = 0; @action.bound add() {} }",
+		"class C {\n  @observable\n  count = 0;\n  @action.bound\n  add() {\n  }\n}")
+}
+
+func TestForAwait(t *testing.T) {
+	expectPrinted(t,
+		"async function f() { for await (const x of stream) { use(x); } }",
+		"async function f() {\n  for await (const x of stream) {\n    use(x);\n  }\n}")
+	// a plain for loop cannot take await
+	if _, err := Parse([]byte("for await (;;) {}")); err == nil {
+		t.Error("expected for await without of to be a parse error")
+	}
+}
+
+func TestNewTarget(t *testing.T) {
+	expectPrinted(t, "function F() { if (!new.target) { throw x; } }",
+		"function F() {\n  if (!new.target) {\n    throw x;\n  }\n}")
+	expectPrinted(t, "var name = new.target.name;", "var name = new.target.name;")
+	// regular construction is unaffected
+	expectPrinted(t, "var f = new Foo(1);", "var f = new Foo(1);")
+	if _, err := Parse([]byte("new.meta")); err == nil {
+		t.Error("expected new.meta to be a parse error")
+	}
+}
+
+func TestImportMeta(t *testing.T) {
+	expectPrinted(t, "var url = import.meta.url;", "var url = import.meta.url;")
+	expectPrinted(t, "f(import.meta);", "f(import.meta);")
+	// a real import declaration still parses as one
+	tree, err := Parse([]byte("import a from './a';"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Children[0].Type != "ImportStatement" {
+		t.Errorf("expected an ImportStatement, got %+v", tree.Children[0])
+	}
+	if _, err := Parse([]byte("import.url")); err == nil {
+		t.Error("expected import.url to be a parse error")
+	}
+}
+
+func TestDynamicImportExpression(t *testing.T) {
+	// import(expr) is a call-like expression anywhere an expression
+	// goes, literal paths are lowered to chunk loads later in
+	// substituteDynamicImports
+	expectPrinted(t, "const m = await import('./x');",
+		"const m = await import('./x');")
+	expectPrinted(t, "import(getPath()).then(use);", "import(getPath()).then(use);")
+	expectPrinted(t, "var p = import('./a' + ext);", "var p = import('./a' + ext);")
+}
@@ -0,0 +1,623 @@
+package jsLoader
+
+import (
+	"strings"
+)
+
+// Minify makes the printer emit compact output: no indentation,
+// no cosmetic whitespace, and no semicolons where ASI makes them
+// optional (before a closing brace and at the end of the output).
+// String and template literal contents are never touched
+var Minify bool
+
+// Mapping connects a position in the printed output to the
+// position of the original statement in the source file
+type Mapping struct {
+	GenLine int
+	GenCol  int
+	SrcLine int
+	SrcCol  int
+}
+
+type astPrinter struct {
+	sb      strings.Builder
+	indent  int
+	genLine int
+	genCol  int
+	minify  bool
+	// pendingSemi delays statement-ending semicolons so they can
+	// be dropped before a closing brace in minify mode
+	pendingSemi bool
+	mappings    []Mapping
+}
+
+func printAst(n ast) string {
+	p := astPrinter{minify: Minify}
+	p.printNode(n)
+	return p.result()
+}
+
+// printAstWithMappings also returns a source map entry for every
+// printed statement that has an original position
+func printAstWithMappings(n ast) (string, []Mapping) {
+	p := astPrinter{minify: Minify}
+	p.printNode(n)
+	return p.result(), p.mappings
+}
+
+func (p *astPrinter) result() string {
+	if p.pendingSemi {
+		p.pendingSemi = false
+		if !p.minify {
+			p.write(";")
+		}
+	}
+	return p.sb.String()
+}
+
+func (p *astPrinter) write(s string) {
+	p.sb.WriteString(s)
+	for _, c := range s {
+		if c == '\n' {
+			p.genLine++
+			p.genCol = 0
+		} else {
+			p.genCol++
+		}
+	}
+}
+
+func (p *astPrinter) out(s string) {
+	if s == "" {
+		return
+	}
+	if p.pendingSemi {
+		p.pendingSemi = false
+		if !(p.minify && s[0] == '}') {
+			p.write(";")
+		}
+	}
+	p.write(s)
+}
+
+func (p *astPrinter) flushSemi() {
+	if p.pendingSemi {
+		p.pendingSemi = false
+		p.write(";")
+	}
+}
+
+// semi ends a statement, the semicolon itself is written lazily
+func (p *astPrinter) semi() {
+	p.pendingSemi = true
+}
+
+// space is cosmetic whitespace that minify mode drops
+func (p *astPrinter) space() {
+	if !p.minify {
+		p.out(" ")
+	}
+}
+
+func (p *astPrinter) newline() {
+	if p.minify {
+		return
+	}
+	p.out("\n")
+	for i := 0; i < p.indent; i++ {
+		p.out("  ")
+	}
+}
+
+func (p *astPrinter) printStatements(statements []ast) {
+	for _, s := range statements {
+		p.newline()
+		p.printNode(s)
+	}
+}
+
+func (p *astPrinter) printNode(n ast) {
+	if n.hasPos {
+		p.flushSemi()
+		p.mappings = append(p.mappings, Mapping{
+			GenLine: p.genLine,
+			GenCol:  p.genCol,
+			SrcLine: n.srcLine,
+			SrcCol:  n.srcCol,
+		})
+	}
+
+	switch n.t {
+	case n_EMPTY:
+
+	case n_PROGRAM:
+		for i, s := range n.children {
+			if i > 0 {
+				p.newline()
+			}
+			p.printNode(s)
+		}
+
+	case n_IDENTIFIER, n_NUMBER_LITERAL, n_STRING_LITERAL, n_REGEX_LITERAL,
+		n_META_PROPERTY:
+		p.out(n.value)
+
+	case n_BOOL_LITERAL, n_UNDEFINED:
+		p.out(literalSpelling(n))
+
+	case n_TEMPLATE_LITERAL:
+		p.out("`")
+		for _, c := range n.children {
+			if c.t == n_TEMPLATE_STRING {
+				p.out(c.value)
+			} else {
+				p.out("${")
+				p.printNode(c)
+				p.out("}")
+			}
+		}
+		p.out("`")
+
+	case n_TAGGED_TEMPLATE:
+		p.printNode(n.children[0])
+		p.printNode(n.children[1])
+
+	case n_ASSIGNMENT:
+		p.printNode(n.children[0])
+		p.space()
+		p.out(n.value)
+		p.space()
+		p.printNode(n.children[1])
+
+	case n_CONDITIONAL:
+		p.printNode(n.children[0])
+		p.space()
+		p.out("?")
+		p.space()
+		p.printNode(n.children[1])
+		p.space()
+		p.out(":")
+		p.space()
+		p.printNode(n.children[2])
+
+	case n_BINARY:
+		// !0 ** 2 is a syntax error, ** rejects unary left operands
+		if left := n.children[0]; n.value == "**" &&
+			(left.t == n_BOOL_LITERAL || left.t == n_UNDEFINED) {
+			p.out(left.value)
+		} else {
+			p.printNode(left)
+		}
+		if isWordOperator(n.value) {
+			p.out(" " + n.value + " ")
+		} else {
+			p.space()
+			p.out(n.value)
+			p.space()
+		}
+		p.printNode(n.children[1])
+
+	case n_PREFIX_UNARY:
+		p.out(n.value)
+		if len(n.value) > 2 && n.children[0].t != n_EMPTY {
+			// word operators like typeof need a space
+			p.out(" ")
+		}
+		p.printNode(n.children[0])
+
+	case n_POSTFIX_UNARY:
+		p.printNode(n.children[0])
+		p.out(n.value)
+
+	case n_MEMBER:
+		// member access binds tighter than the ! and void of the
+		// short spellings, a literal base keeps its long form
+		if base := n.children[0]; base.t == n_BOOL_LITERAL || base.t == n_UNDEFINED {
+			p.out(base.value)
+		} else {
+			p.printNode(base)
+		}
+		switch n.value {
+		case "[", "?.[":
+			p.out(n.value)
+			p.printNode(n.children[1])
+			p.out("]")
+		default:
+			p.out(n.value)
+			p.printNode(n.children[1])
+		}
+
+	case n_CALL:
+		p.printNode(n.children[0])
+		p.out(n.value)
+		p.out("(")
+		p.printNodeList(n.children[1:])
+		p.out(")")
+
+	case n_NEW:
+		p.out("new ")
+		p.printNode(n.children[0])
+		p.out("(")
+		p.printNodeList(n.children[1:])
+		p.out(")")
+
+	case n_SPREAD:
+		p.out("...")
+		p.printNode(n.children[0])
+
+	case n_SEQUENCE:
+		p.printNode(n.children[0])
+		p.out(",")
+		p.space()
+		p.printNode(n.children[1])
+
+	case n_PAREN:
+		p.out("(")
+		p.printNode(n.children[0])
+		p.out(")")
+
+	case n_OBJECT_LITERAL:
+		p.out("{")
+		p.printNodeList(n.children)
+		p.out("}")
+
+	case n_OBJECT_PROP:
+		p.printNode(n.children[0])
+		p.out(":")
+		p.space()
+		p.printNode(n.children[1])
+
+	case n_OBJECT_METHOD:
+		for _, mod := range strings.Fields(n.value) {
+			if mod == "*" {
+				p.out("*")
+			} else {
+				p.out(mod + " ")
+			}
+		}
+		p.printNode(n.children[0])
+		p.printNode(n.children[1])
+		p.space()
+		p.printNode(n.children[2])
+
+	case n_COMPUTED_KEY:
+		p.out("[")
+		p.printNode(n.children[0])
+		p.out("]")
+
+	case n_ARRAY_LITERAL:
+		p.out("[")
+		p.printNodeList(n.children)
+		p.out("]")
+
+	case n_FUNCTION_DECLARATION, n_FUNCTION_EXPRESSION:
+		if strings.HasPrefix(n.value, "async") {
+			p.out("async ")
+		}
+		p.out("function")
+		if strings.HasSuffix(n.value, "*") {
+			p.out("*")
+		}
+		if n.children[0].t != n_EMPTY {
+			p.out(" ")
+			p.printNode(n.children[0])
+		}
+		p.printNode(n.children[1])
+		p.space()
+		p.printNode(n.children[2])
+
+	case n_ARROW_FUNCTION:
+		if strings.HasPrefix(n.value, "async") {
+			p.out("async ")
+		}
+		p.printNode(n.children[0])
+		p.space()
+		p.out("=>")
+		p.space()
+		p.printNode(n.children[1])
+
+	case n_PARAM_LIST:
+		p.out("(")
+		p.printNodeList(n.children)
+		p.out(")")
+
+	case n_VAR_STATEMENT:
+		p.out(n.value + " ")
+		p.printNodeList(n.children)
+		p.semi()
+
+	case n_DECLARATOR:
+		p.printNode(n.children[0])
+		if n.children[1].t != n_EMPTY {
+			p.space()
+			p.out("=")
+			p.space()
+			p.printNode(n.children[1])
+		}
+
+	case n_EXPRESSION_STATEMENT:
+		p.printNode(n.children[0])
+		p.semi()
+
+	case n_BLOCK_STATEMENT:
+		p.out("{")
+		p.indent++
+		p.printStatements(n.children)
+		p.indent--
+		p.newline()
+		p.out("}")
+
+	case n_IF_STATEMENT:
+		p.out("if")
+		p.space()
+		p.out("(")
+		p.printNode(n.children[0])
+		p.out(")")
+		p.space()
+		p.printNode(n.children[1])
+		if n.children[2].t != n_EMPTY {
+			p.space()
+			p.out("else")
+			if n.children[2].t == n_BLOCK_STATEMENT {
+				p.space()
+			} else {
+				p.out(" ")
+			}
+			p.printNode(n.children[2])
+		}
+
+	case n_FOR_STATEMENT:
+		p.out("for")
+		p.space()
+		p.out("(")
+		p.printNode(n.children[0])
+		if n.children[0].t == n_EMPTY {
+			p.flushSemi()
+			p.out(";")
+		}
+		p.flushSemi()
+		p.space()
+		p.printNode(n.children[1])
+		p.out(";")
+		p.space()
+		p.printNode(n.children[2])
+		p.out(")")
+		p.space()
+		p.printNode(n.children[3])
+
+	case n_FOR_IN_STATEMENT:
+		p.out("for")
+		kind := n.value
+		if rest, ok := strings.CutPrefix(kind, "await "); ok {
+			p.out(" await")
+			kind = rest
+		}
+		p.space()
+		p.out("(")
+		p.printForHead(n.children[0])
+		p.out(" " + kind + " ")
+		p.printNode(n.children[1])
+		p.out(")")
+		p.space()
+		p.printNode(n.children[2])
+
+	case n_WHILE_STATEMENT:
+		p.out("while")
+		p.space()
+		p.out("(")
+		p.printNode(n.children[0])
+		p.out(")")
+		p.space()
+		p.printNode(n.children[1])
+
+	case n_DO_WHILE_STATEMENT:
+		p.out("do ")
+		p.printNode(n.children[1])
+		p.flushSemi()
+		p.space()
+		p.out("while")
+		p.space()
+		p.out("(")
+		p.printNode(n.children[0])
+		p.out(")")
+		p.semi()
+
+	case n_RETURN_STATEMENT:
+		p.out("return")
+		if n.children[0].t != n_EMPTY {
+			p.out(" ")
+			p.printNode(n.children[0])
+		}
+		p.semi()
+
+	case n_BREAK_STATEMENT:
+		p.out("break")
+		if n.value != "" {
+			p.out(" " + n.value)
+		}
+		p.semi()
+
+	case n_CONTINUE_STATEMENT:
+		p.out("continue")
+		if n.value != "" {
+			p.out(" " + n.value)
+		}
+		p.semi()
+
+	case n_LABELED_STATEMENT:
+		p.out(n.value + ":")
+		p.space()
+		p.printNode(n.children[0])
+
+	case n_THROW_STATEMENT:
+		p.out("throw ")
+		p.printNode(n.children[0])
+		p.semi()
+
+	case n_TRY_STATEMENT:
+		p.out("try")
+		p.space()
+		p.printNode(n.children[0])
+		if n.children[2].t != n_EMPTY {
+			p.space()
+			p.out("catch")
+			p.space()
+			p.out("(")
+			p.printNode(n.children[1])
+			p.out(")")
+			p.space()
+			p.printNode(n.children[2])
+		}
+		if n.children[3].t != n_EMPTY {
+			p.space()
+			p.out("finally")
+			p.space()
+			p.printNode(n.children[3])
+		}
+
+	case n_SWITCH_STATEMENT:
+		p.out("switch")
+		p.space()
+		p.out("(")
+		p.printNode(n.children[0])
+		p.out(")")
+		p.space()
+		p.out("{")
+		p.indent++
+		for _, c := range n.children[1:] {
+			p.newline()
+			p.printNode(c)
+		}
+		p.indent--
+		p.newline()
+		p.out("}")
+
+	case n_SWITCH_CASE:
+		if n.children[0].t == n_EMPTY {
+			p.out("default:")
+		} else {
+			p.out("case ")
+			p.printNode(n.children[0])
+			p.out(":")
+		}
+		p.indent++
+		p.printStatements(n.children[1:])
+		p.indent--
+
+	case n_CLASS_DECLARATION, n_CLASS_EXPRESSION:
+		p.out("class")
+		if n.children[0].t != n_EMPTY {
+			p.out(" ")
+			p.printNode(n.children[0])
+		}
+		if n.children[1].t != n_EMPTY {
+			p.out(" extends ")
+			p.printNode(n.children[1])
+		}
+		p.space()
+		p.out("{")
+		p.indent++
+		p.printStatements(n.children[2:])
+		p.indent--
+		p.newline()
+		p.out("}")
+
+	case n_DECORATOR:
+		p.out("@")
+		p.printNode(n.children[0])
+		if p.minify {
+			p.out(" ")
+		} else {
+			p.newline()
+		}
+		p.printNode(n.children[1])
+
+	case n_CLASS_FIELD:
+		for _, mod := range strings.Fields(n.value) {
+			p.out(mod + " ")
+		}
+		p.printNode(n.children[0])
+		if n.children[1].t != n_EMPTY {
+			p.space()
+			p.out("=")
+			p.space()
+			p.printNode(n.children[1])
+		}
+		p.semi()
+
+	case n_CLASS_METHOD:
+		for _, mod := range strings.Fields(n.value) {
+			if mod == "*" {
+				p.out("*")
+			} else {
+				p.out(mod + " ")
+			}
+		}
+		p.printNode(n.children[0])
+		p.printNode(n.children[1])
+		p.space()
+		p.printNode(n.children[2])
+
+	case n_IMPORT_CALL:
+		p.out("import(")
+		p.printNode(n.children[0])
+		p.out(")")
+
+	case n_DEBUGGER_STATEMENT:
+		p.out("debugger")
+		p.semi()
+
+	case n_EMPTY_STATEMENT:
+		p.out(";")
+	}
+}
+
+// isWordOperator tells whether a binary operator is a keyword and
+// always needs spaces around it, even in minified output
+func isWordOperator(op string) bool {
+	return op[0] >= 'a' && op[0] <= 'z'
+}
+
+// literalSpelling picks the shortest spelling of a boolean or
+// undefined literal when minifying. !0, !1 and void 0 evaluate to
+// the same values, and because only literal nodes take this path a
+// variable binding that happens to be named undefined is unaffected
+func literalSpelling(n ast) string {
+	if !Minify {
+		return n.value
+	}
+	switch n.value {
+	case "true":
+		return "!0"
+	case "false":
+		return "!1"
+	case "undefined":
+		return "void 0"
+	}
+	return n.value
+}
+
+// printForHead prints a for-in/for-of left side without the
+// trailing semicolon a var statement normally gets
+func (p *astPrinter) printForHead(n ast) {
+	if n.t == n_VAR_STATEMENT {
+		p.out(n.value + " ")
+		p.printNodeList(n.children)
+		return
+	}
+	if n.t == n_EXPRESSION_STATEMENT {
+		p.printNode(n.children[0])
+		return
+	}
+	p.printNode(n)
+}
+
+func (p *astPrinter) printNodeList(nodes []ast) {
+	for i, c := range nodes {
+		if i > 0 {
+			p.out(",")
+			p.space()
+		}
+		p.printNode(c)
+	}
+}
@@ -0,0 +1,154 @@
+package jsLoader
+
+import (
+	"testing"
+)
+
+// expectMinified parses src and checks the minified output
+func expectMinified(t *testing.T, src, expected string) {
+	t.Helper()
+	Minify = true
+	defer func() { Minify = false }()
+
+	program, err := parse(lex([]byte(src)))
+	if err != nil {
+		t.Fatalf("parse error in %q: %v", src, err)
+	}
+	printed := printAst(program)
+	if printed != expected {
+		t.Errorf("minify mismatch:\nsource:   %s\nexpected: %s\ngot:      %s",
+			src, expected, printed)
+	}
+}
+
+func TestMinifyStripsWhitespace(t *testing.T) {
+	expectMinified(t,
+		"var a = 1 + 2;\nvar b = a * 3;",
+		"var a=1+2;var b=a*3")
+	expectMinified(t,
+		"function f(a, b) { return a + b; }",
+		"function f(a,b){return a+b}")
+	expectMinified(t,
+		"if (a) { b(); } else { c(); }",
+		"if(a){b()}else{c()}")
+	expectMinified(t,
+		"for (var i = 0; i < 10; i++) { f(i); }",
+		"for(var i=0;i<10;i++){f(i)}")
+}
+
+func TestLabeledBreakContinue(t *testing.T) {
+	expectMinified(t,
+		"outer: for (;;) { for (;;) { break outer; } }",
+		"outer:for(;;){for(;;){break outer}}")
+	expectMinified(t,
+		"loop: while (a) { continue loop; }",
+		"loop:while(a){continue loop}")
+	// a newline after break ends the statement, the identifier on
+	// the next line is not a label
+	expectMinified(t,
+		"for (;;) { break\nouter; }",
+		"for(;;){break;outer}")
+}
+
+func TestMinifyKeepsWordOperators(t *testing.T) {
+	expectMinified(t,
+		"var x = a in b;",
+		"var x=a in b")
+	expectMinified(t,
+		"var y = a instanceof B;",
+		"var y=a instanceof B")
+	expectMinified(t,
+		"for (var k in obj) f(k);",
+		"for(var k in obj)f(k)")
+}
+
+func TestMinifyKeepsStringContents(t *testing.T) {
+	expectMinified(t,
+		"var s = 'a  b';",
+		"var s='a  b'")
+	expectMinified(t,
+		"var t = `one  ${x}  two`;",
+		"var t=`one  ${x}  two`")
+}
+
+func TestMinifyObjectAndArray(t *testing.T) {
+	expectMinified(t,
+		"var o = {a: 1, b: 2};",
+		"var o={a:1,b:2}")
+	expectMinified(t,
+		"var a = [1, 2, 3];",
+		"var a=[1,2,3]")
+}
+
+func TestObjectMethods(t *testing.T) {
+	expectMinified(t,
+		"var o = { m() { return 1; } };",
+		"var o={m(){return 1}}")
+	expectMinified(t,
+		"var o = { [key]() {}, [a + b]: 1 };",
+		"var o={[key](){},[a+b]:1}")
+	expectMinified(t,
+		"var o = { *gen() {}, async m() {}, async *am() {} };",
+		"var o={*gen(){},async m(){},async *am(){}}")
+	expectMinified(t,
+		"var o = { get x() { return 1; }, set x(v) {} };",
+		"var o={get x(){return 1},set x(v){}}")
+	// get/set/async still work as plain property names
+	expectMinified(t,
+		"var o = { get: 1, async: 2, set };",
+		"var o={get:1,async:2,set:set}")
+}
+
+func TestDebuggerStatement(t *testing.T) {
+	expectPrinted(t, "debugger;", "debugger;")
+}
+
+func TestMinifyLiteralShorthands(t *testing.T) {
+	expectMinified(t,
+		"f(true, false, undefined);",
+		"f(!0,!1,void 0)")
+	expectMinified(t,
+		"if (x === undefined) { g(); }",
+		"if(x===void 0){g()}")
+	// property and binding names spelled like the literals are
+	// identifiers, not values, and keep their spelling
+	expectMinified(t,
+		"var v = a.undefined;",
+		"var v=a.undefined")
+	expectMinified(t,
+		"var o = { undefined: 1, true: 2 };",
+		"var o={undefined:1,true:2}")
+	// member access on a literal keeps the long form, !0.toString()
+	// would negate the member instead
+	expectMinified(t,
+		"var s = true.toString();",
+		"var s=true.toString()")
+	// readable builds keep the long spellings
+	expectPrinted(t, "var a = true;", "var a = true;")
+}
+
+func TestStringEscapeRoundTrip(t *testing.T) {
+	// string lexemes stay raw through lex, parse and print: escapes,
+	// the chosen quote character and line continuations all survive
+	src := "var a = 'it\\'s';\n" +
+		"var b = \"tab\\t newline\\n unicode\\u00e9 hex\\x41 null\\0 \\\\\";\n" +
+		"var c = 'multi\\\nline';\n" +
+		"var d = \"double\\\"quote\";\n" +
+		"var e = '\\u{1F600}';"
+	tree, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	printed := Print(tree)
+	if printed != src {
+		t.Errorf("expected escapes to survive the round-trip:\n%s\ngot:\n%s",
+			src, printed)
+	}
+
+	// a string after a line continuation still counts its lines
+	tokens := lex([]byte("'a\\\nb'\nc"))
+	if tokens[1].lexeme != "c" || tokens[1].line != 2 {
+		t.Errorf("expected c on line 2, got %q on line %v",
+			tokens[1].lexeme, tokens[1].line)
+	}
+}
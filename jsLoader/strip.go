@@ -0,0 +1,74 @@
+package jsLoader
+
+// DropDebugger removes debugger statements from the output
+var DropDebugger bool
+
+// DropConsole lists console methods whose standalone calls are
+// removed, e.g. ["log", "debug"]
+var DropConsole []string
+
+// stripDevStatements removes debugger statements and configured
+// console calls. Only standalone expression statements are
+// dropped: a console call whose value is used stays
+func stripDevStatements(n ast) ast {
+	for i, c := range n.children {
+		n.children[i] = stripDevStatements(c)
+	}
+
+	switch n.t {
+	case n_PROGRAM, n_BLOCK_STATEMENT:
+		kept := n.children[:0]
+		for _, c := range n.children {
+			if !isDroppedStatement(c) {
+				kept = append(kept, c)
+			}
+		}
+		n.children = kept
+
+	case n_SWITCH_CASE:
+		kept := n.children[:1]
+		for _, c := range n.children[1:] {
+			if !isDroppedStatement(c) {
+				kept = append(kept, c)
+			}
+		}
+		n.children = kept
+
+	case n_IF_STATEMENT, n_FOR_STATEMENT, n_FOR_IN_STATEMENT,
+		n_WHILE_STATEMENT, n_DO_WHILE_STATEMENT:
+		// a dropped statement used as a loop or branch body has to
+		// leave an empty statement behind
+		for i, c := range n.children {
+			if isDroppedStatement(c) {
+				n.children[i] = makeNode(n_EMPTY_STATEMENT, "")
+			}
+		}
+	}
+	return n
+}
+
+func isDroppedStatement(n ast) bool {
+	if n.t == n_DEBUGGER_STATEMENT {
+		return DropDebugger
+	}
+	if n.t != n_EXPRESSION_STATEMENT {
+		return false
+	}
+	call := n.children[0]
+	if call.t != n_CALL {
+		return false
+	}
+	callee := call.children[0]
+	if callee.t != n_MEMBER || callee.value != "." {
+		return false
+	}
+	if callee.children[0].t != n_IDENTIFIER || callee.children[0].value != "console" {
+		return false
+	}
+	for _, method := range DropConsole {
+		if callee.children[1].value == method {
+			return true
+		}
+	}
+	return false
+}
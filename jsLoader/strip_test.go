@@ -0,0 +1,43 @@
+package jsLoader
+
+import (
+	"strings"
+	"testing"
+)
+
+func loadWithStripping(t *testing.T, src string, dropDebugger bool, dropConsole []string) string {
+	t.Helper()
+	DropDebugger = dropDebugger
+	DropConsole = dropConsole
+	defer func() {
+		DropDebugger = false
+		DropConsole = nil
+	}()
+
+	data, err := LoadFile([]byte(src), "src/a.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data.Code)
+}
+
+func TestStripDebugger(t *testing.T) {
+	code := loadWithStripping(t, "f(); debugger; g();", true, nil)
+	if strings.Contains(code, "debugger") {
+		t.Errorf("expected debugger to be stripped, got %s", code)
+	}
+	if !strings.Contains(code, "f();") || !strings.Contains(code, "g();") {
+		t.Errorf("other statements should stay, got %s", code)
+	}
+}
+
+func TestStripConsoleCalls(t *testing.T) {
+	src := "console.log('debug'); var x = console.error('kept'); console.warn('w');"
+	code := loadWithStripping(t, src, false, []string{"log", "warn"})
+	if strings.Contains(code, "console.log") || strings.Contains(code, "console.warn") {
+		t.Errorf("expected configured console calls to be stripped, got %s", code)
+	}
+	if !strings.Contains(code, "var x = console.error('kept');") {
+		t.Errorf("console calls whose value is used must stay, got %s", code)
+	}
+}
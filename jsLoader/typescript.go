@@ -0,0 +1,201 @@
+package jsLoader
+
+// Typescript support works by stripping: the parser recognizes
+// type annotations, interface and type alias declarations, 'as'
+// casts and call type arguments, consumes them and keeps nothing.
+// There is no type checking, the emitted code is the runtime
+// subset of the source
+
+// skipTypeAnnotation discards a ': Type' annotation if one is
+// present, a no-op outside typescript files
+func (p *parser) skipTypeAnnotation() {
+	if p.ts && p.lexeme() == ":" {
+		p.next()
+		p.skipType()
+	}
+}
+
+// skipArrowReturnType discards the return annotation between an
+// arrow function's params and its '=>', which must stay
+func (p *parser) skipArrowReturnType() {
+	if p.ts && p.lexeme() == ":" {
+		p.next()
+		p.skipTypeExpr(true)
+	}
+}
+
+// skipType consumes one type expression: a primary type followed
+// by suffixes (member access, generics, index brackets) and
+// union, intersection or function arrows chaining further types
+func (p *parser) skipType() {
+	p.skipTypeExpr(false)
+}
+
+func (p *parser) skipTypeExpr(stopAtArrow bool) {
+	expectType := true
+	for p.tok().tType != tEOF {
+		lexeme := p.lexeme()
+		if expectType {
+			switch {
+			case lexeme == "(" || lexeme == "[" || lexeme == "{":
+				p.skipBalancedType()
+			case lexeme == "typeof" || lexeme == "keyof" ||
+				lexeme == "readonly" || lexeme == "new":
+				p.next()
+				continue
+			case p.tok().tType == tNAME || p.tok().tType == tNUMBER ||
+				p.tok().tType == tSTRING:
+				p.next()
+			default:
+				return
+			}
+			expectType = false
+			continue
+		}
+
+		switch lexeme {
+		case ".":
+			p.next()
+			expectType = true
+		case "<":
+			p.skipTypeArgs()
+		case "[":
+			p.skipBalancedType()
+		case "|", "&":
+			p.next()
+			expectType = true
+		case "=>":
+			if stopAtArrow {
+				return
+			}
+			p.next()
+			expectType = true
+		default:
+			return
+		}
+	}
+}
+
+// skipBalancedType consumes a bracketed group (object types, tuple
+// types, parenthesized types) up to its matching closer
+func (p *parser) skipBalancedType() {
+	depth := 0
+	for p.tok().tType != tEOF {
+		switch p.lexeme() {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+		p.next()
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+// skipTypeArgs consumes a <...> group. The lexer produces '>>' for
+// the end of nested generics like Array<Array<T>>, so the closers
+// can count for more than one level
+func (p *parser) skipTypeArgs() {
+	angle := 0
+	depth := 0
+	for p.tok().tType != tEOF {
+		switch p.lexeme() {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case "<":
+			if depth == 0 {
+				angle++
+			}
+		case ">":
+			if depth == 0 {
+				angle--
+			}
+		case ">>":
+			if depth == 0 {
+				angle -= 2
+			}
+		case ">>>":
+			if depth == 0 {
+				angle -= 3
+			}
+		}
+		p.next()
+		if angle <= 0 && depth == 0 {
+			return
+		}
+	}
+}
+
+// isTypeArgsAhead distinguishes a generic call foo<T>(x) from a
+// comparison: everything up to the matching '>' has to look like
+// type arguments and a call has to follow
+func (p *parser) isTypeArgsAhead() bool {
+	angle := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		switch t.lexeme {
+		case "<":
+			angle++
+		case ">":
+			angle--
+		case ">>":
+			angle -= 2
+		case ">>>":
+			angle -= 3
+		case ",", ".", "[", "]", "|", "&", "extends", "keyof", "typeof":
+			// allowed inside type arguments
+		default:
+			if t.tType != tNAME && t.tType != tSTRING && t.tType != tNUMBER {
+				return false
+			}
+		}
+		if angle <= 0 {
+			return i+1 < len(p.tokens) && p.tokens[i+1].lexeme == "("
+		}
+	}
+	return false
+}
+
+// parseInterfaceDeclaration consumes a whole interface, nothing of
+// it reaches the output
+func (p *parser) parseInterfaceDeclaration() ast {
+	p.expect("interface")
+	p.expectType(tNAME, "interface name")
+	if p.lexeme() == "<" {
+		p.skipTypeArgs()
+	}
+	if p.accept("extends") {
+		for p.lexeme() != "{" && p.tok().tType != tEOF {
+			p.next()
+		}
+	}
+	p.skipBalancedType()
+	return makeNode(n_EMPTY_STATEMENT, "")
+}
+
+// parseTypeAlias consumes a 'type X = ...' declaration
+func (p *parser) parseTypeAlias() ast {
+	p.expect("type")
+	p.expectType(tNAME, "type name")
+	if p.lexeme() == "<" {
+		p.skipTypeArgs()
+	}
+	p.expect("=")
+	p.skipType()
+	p.accept(";")
+	return makeNode(n_EMPTY_STATEMENT, "")
+}
+
+// isTypeAliasAhead tells whether 'type' starts an alias
+// declaration rather than being used as a plain identifier
+func (p *parser) isTypeAliasAhead() bool {
+	if !p.ts || p.lexeme() != "type" || p.pos+2 >= len(p.tokens) {
+		return false
+	}
+	return p.tokens[p.pos+1].tType == tNAME &&
+		(p.tokens[p.pos+2].lexeme == "=" || p.tokens[p.pos+2].lexeme == "<")
+}
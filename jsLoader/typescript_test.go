@@ -0,0 +1,79 @@
+package jsLoader
+
+import "testing"
+
+func expectStripped(t *testing.T, src, expected string) {
+	t.Helper()
+	Minify = true
+	defer func() { Minify = false }()
+
+	program, err := parseTypeScript(lex([]byte(src)))
+	if err != nil {
+		t.Fatalf("parse error in %q: %v", src, err)
+	}
+	printed := printAst(program)
+	if printed != expected {
+		t.Errorf("strip mismatch:\nsource:   %s\nexpected: %s\ngot:      %s",
+			src, expected, printed)
+	}
+}
+
+func TestStripAnnotations(t *testing.T) {
+	expectStripped(t,
+		"var x: number = 1;",
+		"var x=1")
+	expectStripped(t,
+		"function add(a: number, b?: string): void { return a; }",
+		"function add(a,b){return a}")
+	expectStripped(t,
+		"var f: (x: number) => string = g;",
+		"var f=g")
+	expectStripped(t,
+		"const h = (a: number): number => a * 2;",
+		"const h=(a)=>a*2")
+	expectStripped(t,
+		"function pick<T>(list: Array<Array<T>>): T { return list[0][0]; }",
+		"function pick(list){return list[0][0]}")
+}
+
+func TestStripDeclarations(t *testing.T) {
+	expectStripped(t,
+		"interface Point { x: number; y: number; }\nvar p = 1;",
+		";var p=1")
+	expectStripped(t,
+		"type Pair = { a: string, b: number };\nvar q = 2;",
+		";var q=2")
+	expectStripped(t,
+		"export interface Props { name: string; }\nvar r = 3;",
+		";var r=3")
+	// 'type' stays usable as a plain identifier
+	expectStripped(t,
+		"var type = 5;",
+		"var type=5")
+}
+
+func TestStripExpressions(t *testing.T) {
+	expectStripped(t,
+		"var n = value as number;",
+		"var n=value")
+	expectStripped(t,
+		"var c = node!.parent;",
+		"var c=node.parent")
+	expectStripped(t,
+		"first<string>(list);",
+		"first(list)")
+	// comparisons must stay comparisons
+	expectStripped(t,
+		"var ok = a < b;",
+		"var ok=a<b")
+	expectStripped(t,
+		"var both = a < b && c > (d);",
+		"var both=a<b&&c>(d)")
+}
+
+func TestStripClasses(t *testing.T) {
+	expectStripped(t,
+		"class List<T> extends Base<T> implements Sized { "+
+			"size(extra: number): number { return this.n + extra; } }",
+		"class List extends Base{size(extra){return this.n+extra}}")
+}
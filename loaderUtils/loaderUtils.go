@@ -0,0 +1,19 @@
+package loaderUtils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
+
+// HashedFileName makes the output filename for a copied asset:
+// the original base name with the first 8 hex chars of the
+// content's sha1 before the extension, so different files never
+// collide and the name changes whenever the content does
+func HashedFileName(path string, content []byte) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+	sum := sha1.Sum(content)
+	return base + "." + hex.EncodeToString(sum[:])[:8] + ext
+}
@@ -0,0 +1,21 @@
+package loaderUtils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashedFileName(t *testing.T) {
+	a := HashedFileName("img/logo.png", []byte("aaa"))
+	b := HashedFileName("other/logo.png", []byte("bbb"))
+
+	if !strings.HasPrefix(a, "logo.") || !strings.HasSuffix(a, ".png") {
+		t.Errorf("unexpected name format: %s", a)
+	}
+	if a == b {
+		t.Errorf("different contents must give different names, got %s", a)
+	}
+	if a != HashedFileName("img/logo.png", []byte("aaa")) {
+		t.Error("hashing must be stable for the same content")
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lvl5hm/go-bundler/bundleCache"
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+var printOrderFlag = flag.Bool("print-order", false,
+	"print the computed module initialization order")
+
+var dumpAstFlag = flag.Bool("dump-ast", false,
+	"parse the entry file and print its syntax tree instead of building")
+
+type devServerConfig struct {
+	Enable bool `json:"enable"`
+	Port   int  `json:"port"`
+	// HistoryFallback serves index.html for paths that match no
+	// file, so client-side routes of single-page apps work
+	HistoryFallback bool `json:"historyFallback"`
+	// Proxy maps path prefixes to backend urls, matching requests
+	// are reverse-proxied instead of served from the bundle
+	Proxy map[string]string `json:"proxy"`
+	// Open launches the default browser at the server url once on
+	// startup
+	Open bool `json:"open"`
+}
+
+type permanentCacheConfig struct {
+	Enable  bool   `json:"enable"`
+	DirName string `json:"dirName"`
+}
+
+type resolveConfig struct {
+	PreserveSymlinks bool     `json:"preserveSymlinks"`
+	Extensions       []string `json:"extensions"`
+	// Alias maps specifier prefixes to directories, applied before
+	// normal resolution with longest-prefix matching
+	Alias map[string]string `json:"alias"`
+}
+
+type manifestConfig struct {
+	Enable bool `json:"enable"`
+	// Path of the written manifest file, defaults to manifest.json
+	// in the bundle directory
+	Path string `json:"path"`
+}
+
+type jsxConfig struct {
+	// Pragma is the function jsx elements compile to, defaults to
+	// React.createElement
+	Pragma string `json:"pragma"`
+	// Fragment is the component jsx fragments compile to, defaults
+	// to React.Fragment
+	Fragment string `json:"fragment"`
+}
+
+type cssConfig struct {
+	BundleImports bool   `json:"bundleImports"`
+	PublicPath    string `json:"publicPath"`
+	Extract       bool   `json:"extract"`
+}
+
+type configJSON struct {
+	// Mode is "development" or "production", production turns on
+	// minification and sets NODE_ENV to production
+	Mode      string `json:"mode"`
+	Entry     string `json:"entry"`
+	BundleDir string `json:"bundleDir"`
+	// PublicDir is copied verbatim into BundleDir on every build,
+	// for static files no module imports (favicon.ico, robots.txt)
+	PublicDir string `json:"publicDir"`
+	// BundleName is the output filename of the main bundle, a
+	// [hash] or [contenthash] placeholder in it is replaced with
+	// the content hash for long-term caching
+	BundleName string `json:"bundleName"`
+	// Format selects the output shape: "script" (the default)
+	// defines the module functions at the top level, "iife" wraps
+	// the bundle in a function scope, "umd" makes it loadable from
+	// CommonJS, AMD and plain script tags, and "esm" re-exports the
+	// entry module's exports as native module exports. Chunks from
+	// dynamic imports need the top-level script format
+	Format string `json:"format"`
+	// Library is the global name a umd bundle registers itself
+	// under, defaults to "bundle"
+	Library string `json:"library"`
+	// Banner and Footer are written verbatim around the bundle,
+	// outside the module runtime, for license headers and build
+	// metadata. [date] and [version] expand to the build date and
+	// the version from package.json
+	Banner string `json:"banner"`
+	Footer string `json:"footer"`
+	// Compress lists algorithms to pre-compress the written bundle,
+	// chunks and css with, so a static server can serve the .gz
+	// files directly. Only "gzip" ships with the bundler, brotli
+	// has no encoder in the standard library
+	Compress     []string `json:"compress"`
+	TemplateHTML string   `json:"templateHTML"`
+	WatchFiles   bool     `json:"watchFiles"`
+	// WatchDebounceMs is the quiet window the watcher waits after a
+	// change before rebuilding, so a burst of saves coalesces into
+	// a single build. Defaults to 150
+	WatchDebounceMs int               `json:"watchDebounceMs"`
+	SourceMaps      bool              `json:"sourceMaps"`
+	Minify          bool              `json:"minify"`
+	Mangle          bool              `json:"mangle"`
+	TreeShake       bool              `json:"treeShake"`
+	Define          map[string]string `json:"define"`
+	// Externals maps module names to global variable names, their
+	// imports resolve to the global instead of being bundled
+	Externals        map[string]string `json:"externals"`
+	DropDebugger     bool              `json:"dropDebugger"`
+	DropConsole      []string          `json:"dropConsole"`
+	PreserveComments bool              `json:"preserveComments"`
+	Stats            bool              `json:"stats"`
+	// Manifest writes a json file mapping logical output names to
+	// their hashed names, for server-side templates
+	Manifest       manifestConfig       `json:"manifest"`
+	DevServer      devServerConfig      `json:"devServer"`
+	PermanentCache permanentCacheConfig `json:"permanentCache"`
+	Resolve        resolveConfig        `json:"resolve"`
+	JSX            jsxConfig            `json:"jsx"`
+	CSS            cssConfig            `json:"css"`
+}
+
+func loadConfig() (*configJSON, error) {
+	fileName := "go-bundler-config.json"
+	file, err := os.ReadFile(fileName)
+	if err != nil {
+		fileName = "config.json"
+		file, err = os.ReadFile(fileName)
+	}
+	if err != nil {
+		file = []byte("{}")
+	}
+	return parseConfig(file, fileName)
+}
+
+// parseConfig decodes the config json, rejecting unknown keys so a
+// misspelled option fails loudly instead of silently defaulting
+func parseConfig(file []byte, fileName string) (*configJSON, error) {
+	config := &configJSON{
+		Entry:      "index.js",
+		BundleDir:  "build",
+		BundleName: "bundle.js",
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(file))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return config, nil
+}
+
+// validateConfig checks the loaded config against the filesystem
+// and reports every problem with its offending key
+func validateConfig(config *configJSON) error {
+	problems := []string{}
+
+	if _, err := os.Stat(config.Entry); err != nil {
+		problems = append(problems,
+			fmt.Sprintf("entry: %s does not exist", config.Entry))
+	}
+	if config.PublicDir != "" {
+		if stat, err := os.Stat(config.PublicDir); err != nil || !stat.IsDir() {
+			problems = append(problems,
+				fmt.Sprintf("publicDir: %s is not a directory", config.PublicDir))
+		}
+	}
+	switch config.Format {
+	case "", "script", "iife", "umd", "esm":
+	default:
+		problems = append(problems,
+			fmt.Sprintf("format: %q is not script, iife, umd or esm", config.Format))
+	}
+	for _, algo := range config.Compress {
+		switch algo {
+		case "gzip":
+		case "brotli":
+			problems = append(problems,
+				"compress: brotli needs an external encoder, only gzip is supported")
+		default:
+			problems = append(problems,
+				fmt.Sprintf("compress: unknown algorithm %q", algo))
+		}
+	}
+	if port := config.DevServer.Port; port < 0 || port > 65535 {
+		problems = append(problems,
+			fmt.Sprintf("devServer.port: %v is not a valid port", port))
+	}
+	if config.PermanentCache.Enable {
+		dirName := config.PermanentCache.DirName
+		if dirName == "" {
+			dirName = ".go-bundler-cache"
+		}
+		probe := filepath.Join(dirName, ".write-probe")
+		if err := os.MkdirAll(dirName, 0755); err != nil {
+			problems = append(problems, fmt.Sprintf("permanentCache.dirName: %v", err))
+		} else if err := os.WriteFile(probe, nil, 0666); err != nil {
+			problems = append(problems,
+				fmt.Sprintf("permanentCache.dirName: %s is not writable", dirName))
+		} else {
+			os.Remove(probe)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := validateConfig(config); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	applyLoaderOptions(config)
+
+	if *dumpAstFlag {
+		src, err := os.ReadFile(config.Entry)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		tree, err := jsLoader.Parse(src)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Print(jsLoader.DumpAST(tree))
+		return
+	}
+
+	cache := &bundleCache.Cache{}
+	if config.PermanentCache.Enable {
+		dirName := config.PermanentCache.DirName
+		if dirName == "" {
+			dirName = ".go-bundler-cache"
+		}
+		cache = bundleCache.Load(dirName)
+	}
+
+	b, err := createBundle(config, cache)
+	if err != nil {
+		fmt.Println("Build failed:")
+		fmt.Println(err)
+	} else {
+		fmt.Println("Bundle created in", config.BundleDir)
+	}
+	if *printOrderFlag {
+		b.printModuleOrder(os.Stdout)
+	}
+
+	if config.PermanentCache.Enable {
+		cache.Save()
+	}
+
+	// a failed one-shot build has to fail the pipeline running it,
+	// watch and dev server builds stay up to report the next rebuild
+	if err != nil && !config.WatchFiles && !config.DevServer.Enable {
+		os.Exit(1)
+	}
+
+	var server *http.Server
+	if config.DevServer.Enable {
+		server = newDevServer(config)
+		go startDevServer(config, server)
+	}
+
+	if config.WatchFiles || config.DevServer.Enable {
+		go handleInterrupt(config, cache, server)
+	}
+
+	if config.WatchFiles {
+		watchBundledFiles(b, config, cache)
+	} else if config.DevServer.Enable {
+		select {}
+	}
+}
+
+// handleInterrupt shuts the long-running modes down cleanly on
+// ctrl-c: the dev server finishes in-flight requests and the
+// permanent cache is flushed before the process exits
+func handleInterrupt(config *configJSON, cache *bundleCache.Cache, server *http.Server) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	<-interrupts
+
+	fmt.Println("\nStopping...")
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		server.Shutdown(ctx)
+		cancel()
+	}
+	if config.PermanentCache.Enable {
+		cache.Save()
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// encodeVLQ writes a number in the base64 vlq format source maps
+// use: the sign goes into the lowest bit, then the value is split
+// into 5-bit groups with a continuation bit
+func encodeVLQ(sb *strings.Builder, value int) {
+	vlq := value << 1
+	if value < 0 {
+		vlq = (-value << 1) | 1
+	}
+	for {
+		digit := vlq & 0x1f
+		vlq >>= 5
+		if vlq > 0 {
+			digit |= 0x20
+		}
+		sb.WriteByte(base64Chars[digit])
+		if vlq == 0 {
+			break
+		}
+	}
+}
+
+// sourceMapBuilder collects mappings from the modules as they are
+// written into the bundle and produces a version 3 source map
+type sourceMapBuilder struct {
+	sources  []string
+	mappings []mapEntry
+}
+
+type mapEntry struct {
+	genLine  int
+	genCol   int
+	srcIndex int
+	srcLine  int
+	srcCol   int
+}
+
+// addModule records the mappings of one module's code, offset by
+// the line in the bundle where that code starts
+func (m *sourceMapBuilder) addModule(path string, mappings []jsLoader.Mapping, lineOffset int) {
+	srcIndex := len(m.sources)
+	m.sources = append(m.sources, path)
+	for _, mapping := range mappings {
+		m.mappings = append(m.mappings, mapEntry{
+			genLine:  mapping.GenLine + lineOffset,
+			genCol:   mapping.GenCol,
+			srcIndex: srcIndex,
+			srcLine:  mapping.SrcLine,
+			srcCol:   mapping.SrcCol,
+		})
+	}
+}
+
+// build serializes the collected mappings into source map json.
+// Segments on a line are delta-encoded against the previous
+// segment, source fields carry over across lines
+func (m *sourceMapBuilder) build() []byte {
+	var sb strings.Builder
+
+	line := 0
+	prevGenCol := 0
+	prevSrcIndex := 0
+	prevSrcLine := 0
+	prevSrcCol := 0
+	firstOnLine := true
+
+	for _, e := range m.mappings {
+		for line < e.genLine {
+			sb.WriteByte(';')
+			line++
+			prevGenCol = 0
+			firstOnLine = true
+		}
+		if !firstOnLine {
+			sb.WriteByte(',')
+		}
+		encodeVLQ(&sb, e.genCol-prevGenCol)
+		encodeVLQ(&sb, e.srcIndex-prevSrcIndex)
+		encodeVLQ(&sb, e.srcLine-prevSrcLine)
+		encodeVLQ(&sb, e.srcCol-prevSrcCol)
+		prevGenCol = e.genCol
+		prevSrcIndex = e.srcIndex
+		prevSrcLine = e.srcLine
+		prevSrcCol = e.srcCol
+		firstOnLine = false
+	}
+
+	result, _ := json.Marshal(struct {
+		Version  int      `json:"version"`
+		Sources  []string `json:"sources"`
+		Names    []string `json:"names"`
+		Mappings string   `json:"mappings"`
+	}{
+		Version:  3,
+		Sources:  m.sources,
+		Names:    []string{},
+		Mappings: sb.String(),
+	})
+	return result
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lvl5hm/go-bundler/jsLoader"
+)
+
+func TestEncodeVLQ(t *testing.T) {
+	cases := map[int]string{
+		0:   "A",
+		1:   "C",
+		-1:  "D",
+		16:  "gB",
+		123: "2H",
+	}
+	for value, expected := range cases {
+		var sb strings.Builder
+		encodeVLQ(&sb, value)
+		if sb.String() != expected {
+			t.Errorf("encodeVLQ(%d) = %s, expected %s", value, sb.String(), expected)
+		}
+	}
+}
+
+func TestSourceMapBuild(t *testing.T) {
+	m := sourceMapBuilder{}
+	m.addModule("src/index.js", []jsLoader.Mapping{
+		{GenLine: 0, GenCol: 0, SrcLine: 0, SrcCol: 0},
+		{GenLine: 1, GenCol: 0, SrcLine: 2, SrcCol: 4},
+	}, 1)
+
+	result := string(m.build())
+	if !strings.Contains(result, "\"version\":3") {
+		t.Errorf("expected a version 3 map, got %s", result)
+	}
+	if !strings.Contains(result, "src/index.js") {
+		t.Errorf("expected sources to contain the module path, got %s", result)
+	}
+	// line 0 has no mappings, line 1 maps to 0:0, line 2 to 2:4
+	if !strings.Contains(result, "\"mappings\":\";AAAA;AAEI\"") {
+		t.Errorf("unexpected mappings, got %s", result)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lvl5hm/go-bundler/bundleCache"
+)
+
+// bundleDirs lists the directories containing the files of the
+// bundle, these are what the event-driven watcher registers
+func bundleDirs(b *bundle) []string {
+	seen := map[string]bool{}
+	dirs := []string{}
+	for path := range b.files {
+		dir := filepath.Dir(path)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// debounceWindow is how long the watcher lets a burst of file
+// changes settle before rebuilding
+func debounceWindow(config *configJSON) time.Duration {
+	ms := config.WatchDebounceMs
+	if ms <= 0 {
+		ms = 150
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// debounceChanges collects events until the channel stays quiet,
+// a single editor save often produces several in a row
+func debounceChanges(changes <-chan string, quiet time.Duration) []string {
+	collected := []string{}
+	for {
+		select {
+		case path := <-changes:
+			collected = append(collected, path)
+		case <-time.After(quiet):
+			return collected
+		}
+	}
+}
+
+// rebuildBundle refreshes the bundle after the given files changed,
+// incrementally when possible. Tree shaking retransforms modules
+// from whole-graph export usage, so those builds stay full
+func rebuildBundle(b *bundle, config *configJSON, cache *bundleCache.Cache, changed []string) (*bundle, error) {
+	if b == nil || config.TreeShake {
+		return createBundle(config, cache)
+	}
+	if err := b.rebuild(changed); err != nil {
+		return b, err
+	}
+	return b, b.writeOutputs()
+}
+
+// watchBundledFilesPolling polls the modification times of every
+// file in the bundle and rebuilds when one of them changes, used
+// on platforms without a native watch mechanism
+func watchBundledFilesPolling(b *bundle, config *configJSON, cache *bundleCache.Cache) {
+	// fileMissing marks a watched file that is gone from disk, so
+	// re-creating it counts as a change again
+	const fileMissing = -1
+
+	modTimes := map[string]int64{}
+	for path := range b.files {
+		if stat, err := os.Stat(path); err == nil {
+			modTimes[path] = stat.ModTime().UnixNano()
+		} else {
+			modTimes[path] = fileMissing
+		}
+	}
+
+	scan := func() []string {
+		changed := []string{}
+		for path := range modTimes {
+			stat, err := os.Stat(path)
+			if err != nil {
+				// a deleted file is a change too, the rebuild then
+				// surfaces the missing import as a proper error
+				if modTimes[path] != fileMissing {
+					modTimes[path] = fileMissing
+					changed = append(changed, path)
+				}
+				continue
+			}
+			if stat.ModTime().UnixNano() != modTimes[path] {
+				modTimes[path] = stat.ModTime().UnixNano()
+				changed = append(changed, path)
+			}
+		}
+		return changed
+	}
+
+	fmt.Println("Watching for file changes...")
+	for {
+		time.Sleep(300 * time.Millisecond)
+
+		changed := scan()
+		if len(changed) == 0 {
+			continue
+		}
+		// wait until the tree stays quiet for the debounce window,
+		// several files saved at once then cause one rebuild
+		for {
+			time.Sleep(debounceWindow(config))
+			more := scan()
+			if len(more) == 0 {
+				break
+			}
+			changed = append(changed, more...)
+		}
+
+		newBundle, err := rebuildBundle(b, config, cache, changed)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Println("Bundle rebuilt")
+		notifyReload()
+
+		b = newBundle
+		modTimes = map[string]int64{}
+		for path := range b.files {
+			if stat, err := os.Stat(path); err == nil {
+				modTimes[path] = stat.ModTime().UnixNano()
+			}
+		}
+	}
+}
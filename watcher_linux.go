@@ -0,0 +1,149 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/lvl5hm/go-bundler/bundleCache"
+)
+
+// inotifyWatcher gets file change events from the kernel instead
+// of polling modification times, which scales much better with
+// large dependency graphs
+type inotifyWatcher struct {
+	fd      int
+	mu      sync.Mutex
+	watches map[int]string // watch descriptor -> directory
+	watched map[string]bool
+	changes chan string
+}
+
+func newInotifyWatcher() (*inotifyWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		fd:      fd,
+		watches: map[int]string{},
+		watched: map[string]bool{},
+		changes: make(chan string, 64),
+	}
+	go w.readEvents()
+	return w, nil
+}
+
+// watchDirs registers any directories not watched yet. Watching
+// directories instead of single files also catches editors that
+// replace a file on save instead of writing into it
+func (w *inotifyWatcher) watchDirs(dirs []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, dir := range dirs {
+		if w.watched[dir] {
+			continue
+		}
+		// deletes and moves away are watched too, a removed import
+		// should fail the rebuild instead of leaving a stale module
+		wd, err := syscall.InotifyAddWatch(w.fd, dir,
+			syscall.IN_CLOSE_WRITE|syscall.IN_CREATE|syscall.IN_MOVED_TO|syscall.IN_MODIFY|
+				syscall.IN_DELETE|syscall.IN_MOVED_FROM)
+		if err != nil {
+			continue
+		}
+		w.watched[dir] = true
+		w.watches[wd] = dir
+	}
+}
+
+func (w *inotifyWatcher) readEvents() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err != nil {
+			return
+		}
+		offset := 0
+		for offset+syscall.SizeofInotifyEvent <= n {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameLen := int(event.Len)
+
+			name := ""
+			if nameLen > 0 {
+				raw := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+nameLen]
+				name = strings.TrimRight(string(raw), "\x00")
+			}
+
+			w.mu.Lock()
+			dir, ok := w.watches[int(event.Wd)]
+			w.mu.Unlock()
+			if ok && name != "" {
+				w.changes <- filepath.Join(dir, name)
+			}
+
+			offset += syscall.SizeofInotifyEvent + nameLen
+		}
+	}
+}
+
+func (w *inotifyWatcher) close() {
+	syscall.Close(w.fd)
+}
+
+// watchBundledFiles rebuilds the bundle whenever a file in it
+// changes. Events are debounced so one editor save triggers one
+// build, and watches are re-registered after every rebuild since
+// new imports can pull in files from new directories
+func watchBundledFiles(b *bundle, config *configJSON, cache *bundleCache.Cache) {
+	w, err := newInotifyWatcher()
+	if err != nil {
+		watchBundledFilesPolling(b, config, cache)
+		return
+	}
+	defer w.close()
+
+	w.watchDirs(bundleDirs(b))
+	fmt.Println("Watching for file changes...")
+
+	// files of a failed rebuild stay interesting even though they
+	// dropped out of the graph, re-creating or fixing one of them
+	// has to trigger the next attempt
+	failed := map[string]bool{}
+	relevant := func(path string) bool {
+		_, inBundle := b.files[path]
+		return inBundle || failed[path]
+	}
+
+	for path := range w.changes {
+		if !relevant(path) {
+			continue
+		}
+		changed := []string{path}
+		for _, more := range debounceChanges(w.changes, debounceWindow(config)) {
+			if relevant(more) {
+				changed = append(changed, more)
+			}
+		}
+
+		newBundle, err := rebuildBundle(b, config, cache, changed)
+		if err != nil {
+			for _, p := range changed {
+				failed[p] = true
+			}
+			fmt.Println(err)
+			continue
+		}
+		failed = map[string]bool{}
+		fmt.Println("Bundle rebuilt")
+		notifyReload()
+
+		b = newBundle
+		w.watchDirs(bundleDirs(b))
+	}
+}
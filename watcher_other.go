@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"github.com/lvl5hm/go-bundler/bundleCache"
+)
+
+// watchBundledFiles falls back to polling on platforms where we
+// have no native file change notifications
+func watchBundledFiles(b *bundle, config *configJSON, cache *bundleCache.Cache) {
+	watchBundledFilesPolling(b, config, cache)
+}